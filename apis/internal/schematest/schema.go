@@ -0,0 +1,42 @@
+// Package schematest validates manifests against CRD schemas in tests the way API server does, without API server
+package schematest
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/yaml"
+	"testing"
+)
+
+// LoadValidator builds the validator API server uses for custom resources of CRD manifest
+func LoadValidator(t *testing.T, file string) *validate.SchemaValidator {
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	crd := apiextensionsv1.CustomResourceDefinition{}
+	require.NoError(t, yaml.Unmarshal(data, &crd))
+	require.Len(t, crd.Spec.Versions, 1)
+	require.NotNil(t, crd.Spec.Versions[0].Schema)
+
+	crdValidation := apiextensions.CustomResourceValidation{}
+	require.NoError(t, apiextensionsv1.Convert_v1_CustomResourceValidation_To_apiextensions_CustomResourceValidation(
+		crd.Spec.Versions[0].Schema, &crdValidation, nil,
+	))
+	validator, _, err := validation.NewSchemaValidator(&crdValidation)
+	require.NoError(t, err)
+	return validator
+}
+
+// ValidateYAML returns schema violations of manifest as "<field path>: <detail>"
+func ValidateYAML(t *testing.T, validator *validate.SchemaValidator, manifest string) []string {
+	object := map[string]interface{}{}
+	require.NoError(t, yaml.Unmarshal([]byte(manifest), &object))
+	var errs []string
+	for _, err := range validation.ValidateCustomResource(nil, object, validator) {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
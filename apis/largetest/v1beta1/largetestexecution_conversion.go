@@ -0,0 +1,172 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// legacyTimeLayout is the layout of time.Time.String() which was stored in Spec.Time
+const legacyTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// ParseResult converts free form result, e.g. "ok", "success" or "failed", to Result
+func ParseResult(value string) (Result, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "succeeded", "success", "successful", "ok", "passed", "pass":
+		return ResultSucceeded, nil
+	case "failed", "failure", "fail", "ko":
+		return ResultFailed, nil
+	case "error", "errored":
+		return ResultError, nil
+	case "running", "in-progress":
+		return ResultRunning, nil
+	case "pending", "queued":
+		return ResultPending, nil
+	}
+	return "", fmt.Errorf("unknown large test execution result %q, expected one of: %s, %s, %s, %s, %s",
+		value, ResultSucceeded, ResultFailed, ResultError, ResultRunning, ResultPending)
+}
+
+// ParseLegacyTime parses Spec.Time written either as time.Time.String() or RFC3339
+func ParseLegacyTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	// monotonic clock reading is appended by time.Time.String()
+	if index := strings.Index(value, " m="); index >= 0 {
+		value = value[:index]
+	}
+	parsed, err := time.Parse(legacyTimeLayout, value)
+	if err == nil {
+		return parsed, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// GetStartTime returns Status.StartTime, falling back to Spec.Time of executions which are not converted yet
+// and to creation time
+func (in *LargeTestExecution) GetStartTime() metav1.Time {
+	if in.Status.StartTime != nil {
+		return *in.Status.StartTime
+	}
+	if in.Spec.Time != "" {
+		if parsed, err := ParseLegacyTime(in.Spec.Time); err == nil {
+			return metav1.NewTime(parsed)
+		}
+	}
+	return in.CreationTimestamp
+}
+
+// IsCompleted is true when execution has finished with any result
+func (in *LargeTestExecution) IsCompleted() bool {
+	result := in.GetResult()
+	return result != "" && result != ResultRunning && result != ResultPending
+}
+
+// GetResult returns Status.Result, falling back to Spec.Result of executions which are not converted yet
+func (in *LargeTestExecution) GetResult() Result {
+	if in.Status.Result != "" {
+		return in.Status.Result
+	}
+	result, err := ParseResult(in.Spec.Result)
+	if err != nil && in.Spec.Result != "" {
+		return ResultError
+	}
+	return result
+}
+
+// SetResult sets Status.Result and maintains Completed condition, start and completion time,
+// pending execution has not started yet
+func (in *LargeTestExecution) SetResult(result Result, now metav1.Time) {
+	in.Status.Result = result
+	if in.Status.StartTime == nil && result != ResultPending {
+		in.Status.StartTime = now.DeepCopy()
+	}
+
+	condition := metav1.Condition{
+		Type:               ConditionCompleted,
+		Status:             metav1.ConditionTrue,
+		Reason:             string(result),
+		Message:            fmt.Sprintf("large test execution finished with result %s", result),
+		ObservedGeneration: in.Generation,
+	}
+	switch {
+	case result == ResultPending:
+		condition.Status = metav1.ConditionFalse
+		condition.Message = "large test execution is waiting to run"
+		in.Status.CompletionTime = nil
+	case result == ResultRunning:
+		condition.Status = metav1.ConditionFalse
+		condition.Message = "large test execution is running"
+		in.Status.CompletionTime = nil
+	case in.Status.CompletionTime == nil:
+		in.Status.CompletionTime = now.DeepCopy()
+	}
+	meta.SetStatusCondition(&in.Status.Conditions, condition)
+}
+
+// ConvertLegacy fills status of execution created before status subresource from deprecated
+// Spec.Result and Spec.Time, it returns false when there is nothing to convert
+func (in *LargeTestExecution) ConvertLegacy() (bool, error) {
+	if in.Status.Result != "" || in.Spec.Result == "" {
+		return false, nil
+	}
+
+	result, err := ParseResult(in.Spec.Result)
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := in.CreationTimestamp
+	if in.Spec.Time != "" {
+		parsed, err := ParseLegacyTime(in.Spec.Time)
+		if err != nil {
+			return false, fmt.Errorf("can't parse time %q: %w", in.Spec.Time, err)
+		}
+		timestamp = metav1.NewTime(parsed)
+	}
+
+	in.SetResult(result, timestamp)
+	return true, nil
+}
+
+// ValidReport is true when report is an absolute url, the same rule is enforced by CRD schema of Spec.Report
+func ValidReport(report string) bool {
+	parsed, err := url.ParseRequestURI(report)
+	return err == nil && parsed.Scheme != ""
+}
+
+// ConvertLegacyReport moves Spec.Report which is not an url to AnnotationLegacyReport, such execution is rejected
+// by schema on any update otherwise, it returns false when report is valid
+func (in *LargeTestExecution) ConvertLegacyReport() bool {
+	if in.Spec.Report == "" {
+		return false
+	}
+	if ValidReport(in.Spec.Report) {
+		return false
+	}
+
+	if in.Annotations == nil {
+		in.Annotations = map[string]string{}
+	}
+	in.Annotations[AnnotationLegacyReport] = in.Spec.Report
+	in.Spec.Report = ""
+	return true
+}
@@ -0,0 +1,67 @@
+package v1beta1_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"testing"
+	"time"
+)
+
+func TestParseResult(t *testing.T) {
+	for value, expected := range map[string]sdlc.Result{
+		"ok":        sdlc.ResultSucceeded,
+		"success":   sdlc.ResultSucceeded,
+		"Succeeded": sdlc.ResultSucceeded,
+		"failed":    sdlc.ResultFailed,
+		"Error":     sdlc.ResultError,
+		"running":   sdlc.ResultRunning,
+		"pending":   sdlc.ResultPending,
+	} {
+		result, err := sdlc.ParseResult(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, result, value)
+	}
+
+	_, err := sdlc.ParseResult("maybe")
+	assert.Error(t, err)
+}
+
+func TestParseLegacyTime(t *testing.T) {
+	now := time.Date(2021, 4, 20, 10, 11, 12, 0, time.FixedZone("EEST", 3*60*60))
+
+	parsed, err := sdlc.ParseLegacyTime(now.String() + " m=+0.012345678")
+
+	require.NoError(t, err)
+	assert.True(t, now.Equal(parsed), parsed.String())
+}
+
+func TestValidReport(t *testing.T) {
+	for report, valid := range map[string]bool{
+		"https://reports.example.com/reports/1": true,
+		"s3://reports/1/index.html":             true,
+		"/reports/1":                            false,
+		"reports/1":                             false,
+		"not a url":                             false,
+		"":                                      false,
+	} {
+		assert.Equal(t, valid, sdlc.ValidReport(report), report)
+	}
+}
+
+func TestConvertLegacyReport(t *testing.T) {
+	lte := &sdlc.LargeTestExecution{Spec: sdlc.LargeTestExecutionSpec{Report: "rep/ttt/"}}
+
+	assert.True(t, lte.ConvertLegacyReport())
+	assert.Empty(t, lte.Spec.Report)
+	assert.Equal(t, map[string]string{sdlc.AnnotationLegacyReport: "rep/ttt/"}, lte.Annotations)
+	assert.False(t, lte.ConvertLegacyReport())
+
+	lte.Spec.Report = "https://reports.example.com/reports/1"
+	assert.False(t, lte.ConvertLegacyReport())
+	assert.Equal(t, "https://reports.example.com/reports/1", lte.Spec.Report)
+
+	lte.Spec.Report = "/reports/1"
+	assert.True(t, lte.ConvertLegacyReport())
+	assert.Equal(t, "/reports/1", lte.Annotations[sdlc.AnnotationLegacyReport])
+}
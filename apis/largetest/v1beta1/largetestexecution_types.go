@@ -22,15 +22,122 @@ import (
 
 // LargeTestExecutionSpec defines the desired state of LargeTestExecution
 type LargeTestExecutionSpec struct {
-	Image       string       `json:"image,omitempty"`
-	Result      string       `json:"result,omitempty"`
-	Environment string       `json:"environment,omitempty"`
-	Namespace   string       `json:"namespace,omitempty"`
-	Report      string       `json:"report,omitempty"`
-	Time        string       `json:"time,omitempty"`
-	Topology    []AppVersion `json:"topology,omitempty"`
+	Image string `json:"image,omitempty"`
+	// Deprecated: free form result of executions created before status subresource, use Status.Result
+	Result string `json:"result,omitempty"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Environment string `json:"environment,omitempty"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace,omitempty"`
+	// Report is an absolute url of the test report, older free form values are moved to AnnotationLegacyReport
+	// by migration, see ValidReport
+	// +kubebuilder:validation:Format=uri
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z][a-zA-Z0-9+.-]*:`
+	Report string `json:"report,omitempty"`
+	// Deprecated: time.Time.String() of executions created before status subresource, use Status.StartTime
+	Time string `json:"time,omitempty"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Topology []AppVersion `json:"topology,omitempty"`
+	// Commit is the tested revision of Repository
+	Commit     string `json:"commit,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// PipelineID identifies CI run which executed the tests
+	PipelineID string `json:"pipelineId,omitempty"`
+	// Actor is the user who triggered the CI run
+	Actor string `json:"actor,omitempty"`
+	// Archive is a durable copy of the report directory
+	Archive *ReportArchive `json:"archive,omitempty"`
+	// Suite names the kind of executed tests, e.g. smoke or e2e, promotion policy may require several suites
+	Suite string `json:"suite,omitempty"`
 }
 
+// ReportArchive references tar.gz archive of the report directory
+type ReportArchive struct {
+	// Location is s3://<bucket>/<key> or configmap://<namespace>/<name>
+	// +kubebuilder:validation:Required
+	Location string `json:"location"`
+	// Checksum is sha256:<hex> of the archive
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^sha256:[0-9a-f]{64}$`
+	Checksum string `json:"checksum"`
+	// Size of the archive in bytes
+	Size int64 `json:"size,omitempty"`
+}
+
+// Labels mirroring spec fields, values are sanitized to be valid label values
+const (
+	LabelCommit     = "largetest.vitechteam.com/commit"
+	LabelRepository = "largetest.vitechteam.com/repository"
+	LabelPipelineID = "largetest.vitechteam.com/pipeline-id"
+	LabelActor      = "largetest.vitechteam.com/actor"
+	// LabelTopologyHash is a fingerprint of tested topology which allows to find executions by label selector
+	LabelTopologyHash = "largetest.vitechteam.com/topology-hash"
+)
+
+// AnnotationLegacyReport keeps Spec.Report of legacy execution which is not an url
+const AnnotationLegacyReport = "largetest.vitechteam.com/legacy-report"
+
+// Labels of the Job which runs Spec.Image, they reference execution because Job may be in another namespace
+const (
+	LabelExecution          = "largetest.vitechteam.com/execution"
+	LabelExecutionNamespace = "largetest.vitechteam.com/execution-namespace"
+)
+
+// Result is the outcome of large test execution
+// +kubebuilder:validation:Enum=Succeeded;Failed;Error;Running;Pending
+type Result string
+
+const (
+	ResultSucceeded Result = "Succeeded"
+	ResultFailed    Result = "Failed"
+	ResultError     Result = "Error"
+	ResultRunning   Result = "Running"
+	// ResultPending marks execution which waits for the controller to run Spec.Image
+	ResultPending Result = "Pending"
+)
+
+const (
+	// ConditionCompleted is true when execution is finished regardless of its result
+	ConditionCompleted = "Completed"
+)
+
+// LargeTestExecutionStatus defines the observed state of LargeTestExecution
+type LargeTestExecutionStatus struct {
+	Result         Result       `json:"result,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Tests summarizes JUnit reports of the execution
+	Tests *TestSummary `json:"tests,omitempty"`
+	// JobName is the Job in Spec.Namespace which runs Spec.Image of pending execution
+	JobName string `json:"jobName,omitempty"`
+	// Logs references the pod which ran the tests as <namespace>/<pod>
+	Logs string `json:"logs,omitempty"`
+}
+
+// TestSummary is the aggregated result of JUnit test cases
+type TestSummary struct {
+	Total    int             `json:"total"`
+	Passed   int             `json:"passed"`
+	Failed   int             `json:"failed"`
+	Skipped  int             `json:"skipped"`
+	Errors   int             `json:"errors"`
+	Duration metav1.Duration `json:"duration,omitempty"`
+	// FailedTests are names of failed and errored test cases, at most MaxFailedTests of them are kept
+	FailedTests []string `json:"failedTests,omitempty"`
+	// FailedTestsOmitted is a number of failed test names which didn't fit into FailedTests
+	FailedTestsOmitted int `json:"failedTestsOmitted,omitempty"`
+}
+
+// MaxFailedTests limits size of TestSummary.FailedTests to keep the resource small
+const MaxFailedTests = 100
+
+// +kubebuilder:validation:Enum=added;same;removed;updated
 type State string
 
 const (
@@ -40,26 +147,48 @@ const (
 	StateUpdated State = "updated"
 )
 
+// Bump is a semantic versioning change of an updated app
+// +kubebuilder:validation:Enum=major;minor;patch;prerelease;downgrade;unknown
+type Bump string
+
+const (
+	BumpMajor      Bump = "major"
+	BumpMinor      Bump = "minor"
+	BumpPatch      Bump = "patch"
+	BumpPrerelease Bump = "prerelease"
+	BumpDowngrade  Bump = "downgrade"
+	BumpUnknown    Bump = "unknown"
+)
+
 type AppVersion struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
 	Name    string `json:"name,omitempty"`
 	Version string `json:"version,omitempty"`
 	State   State  `json:"state,omitempty"`
+	Bump    Bump   `json:"bump,omitempty"`
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 // +genclient
 // +k8s:openapi-gen=true
 // LargeTestExecution is the Schema for the largetestexecutions API
 // +kubebuilder:printcolumn:name="Env",type=string,JSONPath=`.spec.environment`
 // +kubebuilder:printcolumn:name="Ns",type=string,JSONPath=`.spec.namespace`
 // +kubebuilder:printcolumn:name="Report",type=string,JSONPath=`.spec.report`
-// +kubebuilder:printcolumn:name="Result",type=string,JSONPath=`.spec.result`
-// +kubebuilder:printcolumn:name="Time",type=string,JSONPath=`.spec.time`
+// +kubebuilder:printcolumn:name="Result",type=string,JSONPath=`.status.result`
+// +kubebuilder:printcolumn:name="Started",type=date,JSONPath=`.status.startTime`
+// +kubebuilder:printcolumn:name="Completed",type=date,JSONPath=`.status.completionTime`
+// +kubebuilder:printcolumn:name="Commit",type=string,JSONPath=`.spec.commit`,priority=1
+// +kubebuilder:printcolumn:name="Pipeline",type=string,JSONPath=`.spec.pipelineId`,priority=1
 type LargeTestExecution struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec LargeTestExecutionSpec `json:"spec,omitempty"`
+	// +kubebuilder:validation:Required
+	Spec   LargeTestExecutionSpec   `json:"spec,omitempty"`
+	Status LargeTestExecutionStatus `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
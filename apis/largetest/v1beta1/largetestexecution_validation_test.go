@@ -0,0 +1,98 @@
+package v1beta1_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vitech-team/sdlcctl/apis/internal/schematest"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const crdFile = "../../../config/crd/bases/largetest.vitechteam.com_largetestexecutions.yaml"
+
+func TestLargeTestExecutionSchemaAcceptsSample(t *testing.T) {
+	validator := schematest.LoadValidator(t, crdFile)
+	sample, err := ioutil.ReadFile("../../../config/samples/largetest_v1beta1_largetestexecution.yaml")
+	require.NoError(t, err)
+
+	assert.Empty(t, schematest.ValidateYAML(t, validator, string(sample)))
+}
+
+func TestLargeTestExecutionSchemaAcceptsLegacyRecord(t *testing.T) {
+	validator := schematest.LoadValidator(t, crdFile)
+
+	errs := schematest.ValidateYAML(t, validator, `
+apiVersion: largetest.vitechteam.com/v1beta1
+kind: LargeTestExecution
+spec:
+  environment: staging
+  namespace: jx-staging
+  result: ok
+  time: 2021-03-01 10:00:00.123 +0000 UTC
+  topology:
+  - name: orders-svc
+    version: 2.3.1
+`)
+	assert.Empty(t, errs)
+}
+
+func TestLargeTestExecutionSchemaRejectsInvalidRecords(t *testing.T) {
+	validator := schematest.LoadValidator(t, crdFile)
+
+	tests := map[string]struct {
+		manifest string
+		field    string
+	}{
+		"missing spec": {
+			manifest: `{"kind": "LargeTestExecution"}`,
+			field:    "spec",
+		},
+		"scaffolded sample": {
+			manifest: `{"spec": {"foo": "bar"}}`,
+			field:    "spec.environment",
+		},
+		"empty topology": {
+			manifest: `{"spec": {"environment": "staging", "namespace": "jx-staging", "topology": []}}`,
+			field:    "spec.topology",
+		},
+		"unnamed app": {
+			manifest: `{"spec": {"environment": "staging", "namespace": "jx-staging",
+				"topology": [{"version": "2.3.1"}]}}`,
+			field: "spec.topology.name",
+		},
+		"unknown app state": {
+			manifest: `{"spec": {"environment": "staging", "namespace": "jx-staging",
+				"topology": [{"name": "orders-svc", "state": "moved"}]}}`,
+			field: "spec.topology.state",
+		},
+		"unknown bump": {
+			manifest: `{"spec": {"environment": "staging", "namespace": "jx-staging",
+				"topology": [{"name": "orders-svc", "bump": "huge"}]}}`,
+			field: "spec.topology.bump",
+		},
+		"report is not url": {
+			manifest: `{"spec": {"environment": "staging", "namespace": "jx-staging", "report": "not a url",
+				"topology": [{"name": "orders-svc"}]}}`,
+			field: "spec.report",
+		},
+		"report is relative url": {
+			manifest: `{"spec": {"environment": "staging", "namespace": "jx-staging", "report": "/reports/1",
+				"topology": [{"name": "orders-svc"}]}}`,
+			field: "spec.report",
+		},
+		"unknown result": {
+			manifest: `{"spec": {"environment": "staging", "namespace": "jx-staging",
+				"topology": [{"name": "orders-svc"}]}, "status": {"result": "ok"}}`,
+			field: "status.result",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errs := schematest.ValidateYAML(t, validator, test.manifest)
+			require.NotEmpty(t, errs)
+			assert.Contains(t, strings.Join(errs, "\n"), test.field+":")
+		})
+	}
+}
@@ -21,6 +21,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -45,6 +46,7 @@ func (in *LargeTestExecution) DeepCopyInto(out *LargeTestExecution) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LargeTestExecution.
@@ -105,6 +107,11 @@ func (in *LargeTestExecutionSpec) DeepCopyInto(out *LargeTestExecutionSpec) {
 		*out = make([]AppVersion, len(*in))
 		copy(*out, *in)
 	}
+	if in.Archive != nil {
+		in, out := &in.Archive, &out.Archive
+		*out = new(ReportArchive)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LargeTestExecutionSpec.
@@ -116,3 +123,74 @@ func (in *LargeTestExecutionSpec) DeepCopy() *LargeTestExecutionSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LargeTestExecutionStatus) DeepCopyInto(out *LargeTestExecutionStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Tests != nil {
+		in, out := &in.Tests, &out.Tests
+		*out = new(TestSummary)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LargeTestExecutionStatus.
+func (in *LargeTestExecutionStatus) DeepCopy() *LargeTestExecutionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LargeTestExecutionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportArchive) DeepCopyInto(out *ReportArchive) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportArchive.
+func (in *ReportArchive) DeepCopy() *ReportArchive {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportArchive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestSummary) DeepCopyInto(out *TestSummary) {
+	*out = *in
+	out.Duration = in.Duration
+	if in.FailedTests != nil {
+		in, out := &in.FailedTests, &out.FailedTests
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestSummary.
+func (in *TestSummary) DeepCopy() *TestSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(TestSummary)
+	in.DeepCopyInto(out)
+	return out
+}
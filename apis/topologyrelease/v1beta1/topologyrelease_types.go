@@ -22,15 +22,28 @@ import (
 
 // TopologyReleaseSpec defines the desired state of TopologyRelease
 type TopologyReleaseSpec struct {
-	Environment    string       `json:"environment,omitempty"`
-	Version        string       `json:"version,omitempty"`
-	PrevVersion    string       `json:"prevVersion,omitempty"`
-	PrevEnvVersion string       `json:"prevEnvVersion,omitempty"`
-	ChangelogURL   string       `json:"changelogURL,omitempty"`
-	Topology       []AppVersion `json:"topology,omitempty"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Environment string `json:"environment,omitempty"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`
+	Version string `json:"version,omitempty"`
+	// +kubebuilder:validation:Pattern=`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`
+	PrevVersion string `json:"prevVersion,omitempty"`
+	// +kubebuilder:validation:Pattern=`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`
+	PrevEnvVersion string `json:"prevEnvVersion,omitempty"`
+	// +kubebuilder:validation:Format=uri
+	ChangelogURL string `json:"changelogURL,omitempty"`
+	// Topology is empty when every app has been removed from environment
+	Topology []AppVersion `json:"topology,omitempty"`
 }
 
+// LabelTopologyHash is a fingerprint of released topology, the same as of LargeTestExecution which tested it
+const LabelTopologyHash = "topologyrelease.vitechteam.com/topology-hash"
+
 type AppVersion struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
 	Name     string `json:"name,omitempty"`
 	Version  string `json:"version,omitempty"`
 	GitURL   string `json:"gitURL,omitempty"`
@@ -50,6 +63,7 @@ type TopologyRelease struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
+	// +kubebuilder:validation:Required
 	Spec TopologyReleaseSpec `json:"spec,omitempty"`
 }
 
@@ -0,0 +1,78 @@
+package v1beta1_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vitech-team/sdlcctl/apis/internal/schematest"
+	"strings"
+	"testing"
+)
+
+const crdFile = "../../../config/crd/bases/topologyrelease.vitechteam.com_topologyrelease.yaml"
+
+func TestTopologyReleaseSchemaAcceptsRelease(t *testing.T) {
+	validator := schematest.LoadValidator(t, crdFile)
+
+	errs := schematest.ValidateYAML(t, validator, `
+apiVersion: topologyrelease.vitechteam.com/v1beta1
+kind: TopologyRelease
+spec:
+  environment: staging
+  version: v0.0.2-staging
+  prevVersion: v0.0.1-staging
+  prevEnvVersion: 0.0.1+build.7
+  changelogURL: https://github.com/vitech-team/environment/releases/tag/v0.0.2-staging
+  topology:
+  - name: orders-svc
+    version: 2.3.1
+    gitURL: https://github.com/vitech-team/orders-svc.git
+    revision: 4b825dc642cb6eb9a060e54bf8d69288fbee4904
+`)
+	assert.Empty(t, errs)
+}
+
+func TestTopologyReleaseSchemaRejectsInvalidRecords(t *testing.T) {
+	validator := schematest.LoadValidator(t, crdFile)
+
+	tests := map[string]struct {
+		manifest string
+		field    string
+	}{
+		"missing spec": {
+			manifest: `{"kind": "TopologyRelease"}`,
+			field:    "spec",
+		},
+		"missing version": {
+			manifest: `{"spec": {"environment": "staging", "topology": []}}`,
+			field:    "spec.version",
+		},
+		"missing environment": {
+			manifest: `{"spec": {"version": "v0.0.1-staging", "topology": []}}`,
+			field:    "spec.environment",
+		},
+		"version is not semver": {
+			manifest: `{"spec": {"environment": "staging", "version": "v1.2", "topology": []}}`,
+			field:    "spec.version",
+		},
+		"previous version is not semver": {
+			manifest: `{"spec": {"environment": "staging", "version": "v0.0.2", "prevVersion": "latest"}}`,
+			field:    "spec.prevVersion",
+		},
+		"changelog is not url": {
+			manifest: `{"spec": {"environment": "staging", "version": "v0.0.2", "changelogURL": "CHANGELOG.md"}}`,
+			field:    "spec.changelogURL",
+		},
+		"unnamed app": {
+			manifest: `{"spec": {"environment": "staging", "version": "v0.0.2", "topology": [{"version": "1.0.0"}]}}`,
+			field:    "spec.topology.name",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			errs := schematest.ValidateYAML(t, validator, test.manifest)
+			require.NotEmpty(t, errs)
+			assert.Contains(t, strings.Join(errs, "\n"), test.field+":")
+		})
+	}
+}
@@ -36,9 +36,9 @@ type FakeLargeTestExecutions struct {
 	ns   string
 }
 
-var largetestexecutionsResource = schema.GroupVersionResource{Group: "largetest", Version: "v1beta1", Resource: "largetestexecutions"}
+var largetestexecutionsResource = schema.GroupVersionResource{Group: "largetest.vitechteam.com", Version: "v1beta1", Resource: "largetestexecutions"}
 
-var largetestexecutionsKind = schema.GroupVersionKind{Group: "largetest", Version: "v1beta1", Kind: "LargeTestExecution"}
+var largetestexecutionsKind = schema.GroupVersionKind{Group: "largetest.vitechteam.com", Version: "v1beta1", Kind: "LargeTestExecution"}
 
 // Get takes name of the largeTestExecution, and returns the corresponding largeTestExecution object, and an error if there is any.
 func (c *FakeLargeTestExecutions) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.LargeTestExecution, err error) {
@@ -102,6 +102,18 @@ func (c *FakeLargeTestExecutions) Update(ctx context.Context, largeTestExecution
 	return obj.(*v1beta1.LargeTestExecution), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeLargeTestExecutions) UpdateStatus(ctx context.Context, largeTestExecution *v1beta1.LargeTestExecution, opts v1.UpdateOptions) (*v1beta1.LargeTestExecution, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(largetestexecutionsResource, "status", c.ns, largeTestExecution), &v1beta1.LargeTestExecution{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.LargeTestExecution), err
+}
+
 // Delete takes name of the largeTestExecution and deletes it. Returns an error if one occurs.
 func (c *FakeLargeTestExecutions) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
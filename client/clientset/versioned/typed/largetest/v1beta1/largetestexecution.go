@@ -40,6 +40,7 @@ type LargeTestExecutionsGetter interface {
 type LargeTestExecutionInterface interface {
 	Create(ctx context.Context, largeTestExecution *v1beta1.LargeTestExecution, opts v1.CreateOptions) (*v1beta1.LargeTestExecution, error)
 	Update(ctx context.Context, largeTestExecution *v1beta1.LargeTestExecution, opts v1.UpdateOptions) (*v1beta1.LargeTestExecution, error)
+	UpdateStatus(ctx context.Context, largeTestExecution *v1beta1.LargeTestExecution, opts v1.UpdateOptions) (*v1beta1.LargeTestExecution, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1beta1.LargeTestExecution, error)
@@ -135,6 +136,22 @@ func (c *largeTestExecutions) Update(ctx context.Context, largeTestExecution *v1
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *largeTestExecutions) UpdateStatus(ctx context.Context, largeTestExecution *v1beta1.LargeTestExecution, opts v1.UpdateOptions) (result *v1beta1.LargeTestExecution, err error) {
+	result = &v1beta1.LargeTestExecution{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("largetestexecutions").
+		Name(largeTestExecution.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(largeTestExecution).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the largeTestExecution and deletes it. Returns an error if one occurs.
 func (c *largeTestExecutions) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -36,9 +36,9 @@ type FakeTopologyReleases struct {
 	ns   string
 }
 
-var topologyreleasesResource = schema.GroupVersionResource{Group: "topologyrelease", Version: "v1beta1", Resource: "topologyreleases"}
+var topologyreleasesResource = schema.GroupVersionResource{Group: "topologyrelease.vitechteam.com", Version: "v1beta1", Resource: "topologyreleases"}
 
-var topologyreleasesKind = schema.GroupVersionKind{Group: "topologyrelease", Version: "v1beta1", Kind: "TopologyRelease"}
+var topologyreleasesKind = schema.GroupVersionKind{Group: "topologyrelease.vitechteam.com", Version: "v1beta1", Kind: "TopologyRelease"}
 
 // Get takes name of the topologyRelease, and returns the corresponding topologyRelease object, and an error if there is any.
 func (c *FakeTopologyReleases) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.TopologyRelease, err error) {
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	"github.com/vitech-team/sdlcctl/controllers"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"os"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// LeaderElectionID is the lock which lets a single controller replica reconcile executions
+const LeaderElectionID = "sdlc-largetest-controller.vitechteam.com"
+
+type ControllerOptions struct {
+	MetricsAddr string
+	LeaderElect bool
+	Namespace   string
+	*utils.Options
+}
+
+var log = logrus.New()
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	log.SetFormatter(&logrus.TextFormatter{
+		DisableColors: false,
+		FullTimestamp: true,
+	})
+
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = largetestv1beta1.AddToScheme(scheme)
+}
+
+func NewControllerCmd(rootOpts *utils.Options) (*cobra.Command, *ControllerOptions) {
+	options := &ControllerOptions{Options: rootOpts}
+
+	command := &cobra.Command{
+		Use:     "controller",
+		Short:   "run pending LargeTestExecution images as Jobs and report their results",
+		Example: "sdlc controller --leader-elect",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := options.Run()
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	command.Flags().StringVar(&options.MetricsAddr, "metrics-addr", ":8080", "address the metrics endpoint binds to, 0 disables it")
+	command.Flags().BoolVar(&options.LeaderElect, "leader-elect", false, "enable leader election to run several replicas")
+	command.Flags().StringVarP(
+		&options.Namespace, "namespace", "n", "", "namespace of watched large test executions and of their Jobs, all namespaces if empty",
+	)
+
+	return command, options
+}
+
+func (opt *ControllerOptions) Run() error {
+	ctrl.SetLogger(zap.New())
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return fmt.Errorf("can't load kubernetes config: %w", err)
+	}
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
+		Scheme:             scheme,
+		MetricsBindAddress: opt.MetricsAddr,
+		LeaderElection:     opt.LeaderElect,
+		LeaderElectionID:   LeaderElectionID,
+		Namespace:          opt.Namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("can't create controller manager: %w", err)
+	}
+
+	err = (&controllers.LargeTestExecutionReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		// Jobs are watched in the same namespace as executions
+		Namespace: opt.Namespace,
+		Log:       ctrl.Log.WithName("controllers").WithName("LargeTestExecution"),
+	}).SetupWithManager(mgr)
+	if err != nil {
+		return fmt.Errorf("can't create LargeTestExecution controller: %w", err)
+	}
+
+	log.WithField("namespace", opt.Namespace).Info("starting LargeTestExecution controller")
+	return mgr.Start(ctrl.SetupSignalHandler())
+}
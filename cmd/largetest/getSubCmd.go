@@ -0,0 +1,165 @@
+package largetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	"io"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"time"
+)
+
+type OptionsGet struct {
+	Output string
+	*LargeTestOptions
+}
+
+func makeGetCmd(options *LargeTestOptions) *cobra.Command {
+	opt := &OptionsGet{LargeTestOptions: options}
+
+	getCmd := &cobra.Command{
+		Use:     "get <name>",
+		Short:   "print large test execution",
+		Example: "sdlc largetest get jx-staging-abc123xyz -n jx-staging -o json",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := opt.Get(os.Stdout, args[0])
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	getCmd.Flags().StringVarP(
+		&opt.Output, "output", "o", topology.OutputYAML, "output format: table, json or yaml",
+	)
+
+	return getCmd
+}
+
+func makeDescribeCmd(options *LargeTestOptions) *cobra.Command {
+	describeCmd := &cobra.Command{
+		Use:     "describe <name>",
+		Short:   "print human readable details of large test execution",
+		Example: "sdlc largetest describe jx-staging-abc123xyz -n jx-staging",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := options.Describe(os.Stdout, args[0])
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	return describeCmd
+}
+
+func (opt *OptionsGet) Get(out io.Writer, name string) error {
+	if err := topology.ValidateOutputFormat(opt.Output); err != nil {
+		return err
+	}
+	lte, err := opt.getExecution(name)
+	if err != nil {
+		return err
+	}
+
+	if opt.Output == topology.OutputTable {
+		renderExecutionsTable(out, []sdlc.LargeTestExecution{*lte})
+		return nil
+	}
+	return topology.WriteStructured(out, lte, opt.Output)
+}
+
+func (opt *LargeTestOptions) Describe(out io.Writer, name string) error {
+	lte, err := opt.getExecution(name)
+	if err != nil {
+		return err
+	}
+	describeExecution(out, lte)
+	return nil
+}
+
+func (opt *LargeTestOptions) getExecution(name string) (*sdlc.LargeTestExecution, error) {
+	if opt.Namespace == "" {
+		return nil, fmt.Errorf("--namespace of large test execution %s is required", name)
+	}
+
+	opt.KubeClient, opt.JxClient, opt.LtClient = utils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
+	lte, err := opt.LtClient.LargetestV1beta1().LargeTestExecutions(opt.Namespace).Get(
+		context.TODO(), name, metav1.GetOptions{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't get large test execution %s/%s: %w", opt.Namespace, name, err)
+	}
+	return lte, nil
+}
+
+func describeExecution(out io.Writer, lte *sdlc.LargeTestExecution) {
+	field := func(name string, value interface{}) {
+		fmt.Fprintf(out, "%-13s%v\n", name+":", value)
+	}
+	formatTime := func(value *metav1.Time) string {
+		if value == nil {
+			return ""
+		}
+		return value.UTC().Format(time.RFC3339)
+	}
+
+	started := lte.GetStartTime()
+	field("Name", lte.Name)
+	field("Namespace", lte.Namespace)
+	field("Environment", lte.Spec.Environment)
+	field("Image", lte.Spec.Image)
+	field("Suite", lte.Spec.Suite)
+	field("Report", lte.Spec.Report)
+	if archive := lte.Spec.Archive; archive != nil {
+		field("Archive", archive.Location)
+		field("Checksum", archive.Checksum)
+	}
+	field("Repository", lte.Spec.Repository)
+	field("Commit", lte.Spec.Commit)
+	field("Pipeline", lte.Spec.PipelineID)
+	field("Actor", lte.Spec.Actor)
+	field("Result", lte.GetResult())
+	field("Started", formatTime(&started))
+	field("Completed", formatTime(lte.Status.CompletionTime))
+	field("Duration", executionDuration(lte))
+
+	if tests := lte.Status.Tests; tests != nil {
+		fmt.Fprintln(out, "Tests:")
+		fmt.Fprintf(out, "  Total: %d, Passed: %d, Failed: %d, Errors: %d, Skipped: %d, Duration: %s\n",
+			tests.Total, tests.Passed, tests.Failed, tests.Errors, tests.Skipped, tests.Duration.Duration)
+		for _, name := range tests.FailedTests {
+			fmt.Fprintf(out, "  - %s\n", name)
+		}
+		if tests.FailedTestsOmitted > 0 {
+			fmt.Fprintf(out, "  ... and %d more failed tests\n", tests.FailedTestsOmitted)
+		}
+	}
+
+	if len(lte.Status.Conditions) > 0 {
+		fmt.Fprintln(out, "Conditions:")
+		for _, condition := range lte.Status.Conditions {
+			fmt.Fprintf(out, "  %s=%s %s: %s\n", condition.Type, condition.Status, condition.Reason, condition.Message)
+		}
+	}
+
+	fmt.Fprintln(out, "Topology:")
+	var data [][]string
+	for _, app := range lte.Spec.Topology {
+		data = append(data, []string{app.Name, app.Version})
+	}
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"App", "Version"})
+	table.AppendBulk(data)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.Render()
+}
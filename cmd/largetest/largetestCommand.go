@@ -0,0 +1,47 @@
+package largetest
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+)
+
+type LargeTestOptions struct {
+	Namespace string
+	DryRun    bool
+	*utils.Options
+}
+
+var log = logrus.New()
+
+func init() {
+	log.SetFormatter(&logrus.TextFormatter{
+		DisableColors: false,
+		FullTimestamp: true,
+	})
+}
+
+func NewLargeTestCmd(rootOpts *utils.Options) (*cobra.Command, *LargeTestOptions) {
+	options := &LargeTestOptions{Options: rootOpts}
+
+	command := &cobra.Command{
+		Use:     "largetest",
+		Short:   "manage LargeTestExecution resources",
+		Example: "sdlc largetest migrate --dry-run",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+
+	command.PersistentFlags().StringVarP(
+		&options.Namespace, "namespace", "n", "", "namespace of large test executions, all namespaces if empty",
+	)
+
+	command.AddCommand(makeMigrateCmd(options))
+	command.AddCommand(makePruneCmd(options))
+	command.AddCommand(makeListCmd(options))
+	command.AddCommand(makeGetCmd(options))
+	command.AddCommand(makeDescribeCmd(options))
+
+	return command, options
+}
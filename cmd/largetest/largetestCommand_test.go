@@ -0,0 +1,24 @@
+package largetest_test
+
+import (
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+var (
+	fixtureNow      = time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	oldTopology     = []sdlc.AppVersion{{Name: "orders", Version: "1.0.0"}, {Name: "billing", Version: "2.0.0"}}
+	currentTopology = []sdlc.AppVersion{{Name: "search", Version: "0.1.0"}, {Name: "orders", Version: "1.1.0"}, {Name: "billing", Version: "2.0.0"}}
+)
+
+// execution has been started daysAgo before fixtureNow in jx-<env> namespace
+func execution(name string, env string, result sdlc.Result, daysAgo int, topology []sdlc.AppVersion) *sdlc.LargeTestExecution {
+	namespace := "jx-" + env
+	started := metav1.NewTime(fixtureNow.AddDate(0, 0, -daysAgo))
+	return &sdlc.LargeTestExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       sdlc.LargeTestExecutionSpec{Environment: env, Namespace: namespace, Topology: topology},
+		Status:     sdlc.LargeTestExecutionStatus{Result: result, StartTime: &started},
+	}
+}
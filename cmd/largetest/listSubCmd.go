@@ -0,0 +1,231 @@
+package largetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	"io"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type OptionsList struct {
+	Selector    string
+	Environment string
+	Result      string
+	Apps        []string
+	Since       string
+	Until       string
+	Output      string
+	*LargeTestOptions
+}
+
+// ExecutionFilter selects executions, zero fields match everything
+type ExecutionFilter struct {
+	Environment string
+	Result      sdlc.Result
+	// Apps are either app names or name:version pairs which all must be in execution topology
+	Apps  []string
+	Since *time.Time
+	Until *time.Time
+}
+
+func makeListCmd(options *LargeTestOptions) *cobra.Command {
+	opt := &OptionsList{LargeTestOptions: options}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "list large test executions, the latest first",
+		Example: "sdlc largetest list --env staging --app orders-svc:2.3.1 --result Succeeded\n" +
+			"sdlc largetest list -l " + sdlc.LabelPipelineID + "=42",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			_, err := opt.List(os.Stdout, time.Now())
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	listCmd.Flags().StringVarP(
+		&opt.Selector, "selector", "l", "", "label selector, e.g. "+sdlc.LabelCommit+"=<sha>",
+	)
+	listCmd.Flags().StringVarP(&opt.Environment, "env", "", "", "environment name")
+	listCmd.Flags().StringVarP(&opt.Result, "result", "", "", "result: Succeeded, Failed, Error, Running or Pending")
+	listCmd.Flags().StringSliceVarP(
+		&opt.Apps, "app", "", nil, "app name or name:version which must be in tested topology, may be repeated",
+	)
+	listCmd.Flags().StringVarP(
+		&opt.Since, "since", "", "", "executions started after RFC3339 time or duration ago, e.g. 168h",
+	)
+	listCmd.Flags().StringVarP(
+		&opt.Until, "until", "", "", "executions started before RFC3339 time or duration ago",
+	)
+	listCmd.Flags().StringVarP(
+		&opt.Output, "output", "o", topology.OutputTable, "output format: table, json or yaml",
+	)
+
+	return listCmd
+}
+
+// List prints and returns executions matching filters sorted by start time, the latest first
+func (opt *OptionsList) List(out io.Writer, now time.Time) ([]sdlc.LargeTestExecution, error) {
+	if err := topology.ValidateOutputFormat(opt.Output); err != nil {
+		return nil, err
+	}
+	filter, err := opt.filter(now)
+	if err != nil {
+		return nil, err
+	}
+
+	opt.KubeClient, opt.JxClient, opt.LtClient = utils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
+	executions, err := opt.LtClient.LargetestV1beta1().LargeTestExecutions(opt.Namespace).List(
+		context.TODO(), metav1.ListOptions{LabelSelector: opt.Selector},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't list large test executions: %w", err)
+	}
+
+	matched := []sdlc.LargeTestExecution{}
+	for _, lte := range executions.Items {
+		if filter.Matches(&lte) {
+			matched = append(matched, lte)
+		}
+	}
+	SortLatestFirst(matched)
+
+	if opt.Output == topology.OutputTable {
+		renderExecutionsTable(out, matched)
+		return matched, nil
+	}
+	return matched, topology.WriteStructured(out, matched, opt.Output)
+}
+
+func (opt *OptionsList) filter(now time.Time) (ExecutionFilter, error) {
+	filter := ExecutionFilter{Environment: opt.Environment, Apps: opt.Apps}
+
+	if opt.Result != "" {
+		result, err := sdlc.ParseResult(opt.Result)
+		if err != nil {
+			return filter, err
+		}
+		filter.Result = result
+	}
+
+	var err error
+	if filter.Since, err = parseTimeBound(opt.Since, now); err != nil {
+		return filter, fmt.Errorf("invalid --since: %w", err)
+	}
+	if filter.Until, err = parseTimeBound(opt.Until, now); err != nil {
+		return filter, fmt.Errorf("invalid --until: %w", err)
+	}
+	return filter, nil
+}
+
+// parseTimeBound parses either RFC3339 time or a duration counted back from now
+func parseTimeBound(value string, now time.Time) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		bound := now.Add(-duration)
+		return &bound, nil
+	}
+	bound, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither RFC3339 time nor duration", value)
+	}
+	return &bound, nil
+}
+
+func (filter ExecutionFilter) Matches(lte *sdlc.LargeTestExecution) bool {
+	if filter.Environment != "" && lte.Spec.Environment != filter.Environment {
+		return false
+	}
+	if filter.Result != "" && lte.GetResult() != filter.Result {
+		return false
+	}
+
+	started := lte.GetStartTime()
+	if filter.Since != nil && started.Time.Before(*filter.Since) {
+		return false
+	}
+	if filter.Until != nil && started.Time.After(*filter.Until) {
+		return false
+	}
+
+	for _, app := range filter.Apps {
+		if !containsApp(lte.Spec.Topology, app) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsApp(topology []sdlc.AppVersion, app string) bool {
+	name, version := app, ""
+	if index := strings.Index(app, ":"); index >= 0 {
+		name, version = app[:index], app[index+1:]
+	}
+	for _, tested := range utils.ActiveTopology(topology) {
+		if tested.Name == name && (version == "" || tested.Version == version) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortLatestFirst sorts executions by start time descending
+func SortLatestFirst(executions []sdlc.LargeTestExecution) {
+	sort.SliceStable(executions, func(i, j int) bool {
+		left, right := executions[i].GetStartTime(), executions[j].GetStartTime()
+		return right.Before(&left)
+	})
+}
+
+func renderExecutionsTable(out io.Writer, executions []sdlc.LargeTestExecution) {
+	var data [][]string
+	for _, lte := range executions {
+		started := lte.GetStartTime()
+		data = append(data, []string{
+			lte.Namespace,
+			lte.Name,
+			lte.Spec.Environment,
+			string(lte.GetResult()),
+			started.UTC().Format(time.RFC3339),
+			executionDuration(&lte),
+			testsSummary(lte.Status.Tests),
+			lte.Spec.Report,
+		})
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Ns", "Name", "Env", "Result", "Started", "Duration", "Tests", "Report"})
+
+	table.AppendBulk(data)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.Render()
+}
+
+func executionDuration(lte *sdlc.LargeTestExecution) string {
+	if lte.Status.StartTime == nil || lte.Status.CompletionTime == nil {
+		return ""
+	}
+	return lte.Status.CompletionTime.Sub(lte.Status.StartTime.Time).Round(time.Second).String()
+}
+
+func testsSummary(tests *sdlc.TestSummary) string {
+	if tests == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d passed", tests.Passed, tests.Total)
+}
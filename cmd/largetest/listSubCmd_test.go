@@ -0,0 +1,128 @@
+package largetest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcFake "github.com/vitech-team/sdlcctl/client/clientset/versioned/fake"
+	"github.com/vitech-team/sdlcctl/cmd/largetest"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func queryOptions() *largetest.LargeTestOptions {
+	staging := execution("staging-1", "staging", sdlc.ResultSucceeded, 1, currentTopology)
+	completed := metav1.NewTime(staging.Status.StartTime.Add(90 * time.Second))
+	staging.Status.CompletionTime = &completed
+	staging.Status.Tests = &sdlc.TestSummary{Total: 3, Passed: 2, Failed: 1, FailedTests: []string{"orders.cancel"}}
+
+	stagingFailed := execution("staging-2", "staging", sdlc.ResultFailed, 3, oldTopology)
+	stagingFailed.Labels = map[string]string{sdlc.LabelCommit: "abc"}
+
+	production := execution("production-1", "production", sdlc.ResultSucceeded, 2, oldTopology)
+
+	return &largetest.LargeTestOptions{Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   sdlcFake.NewSimpleClientset(staging, stagingFailed, production),
+	}}
+}
+
+func executionNames(executions []sdlc.LargeTestExecution) []string {
+	var result []string
+	for _, lte := range executions {
+		result = append(result, lte.Name)
+	}
+	return result
+}
+
+func TestListLatestFirst(t *testing.T) {
+	opt := &largetest.OptionsList{Output: topology.OutputTable, LargeTestOptions: queryOptions()}
+	out := &bytes.Buffer{}
+
+	executions, err := opt.List(out, fixtureNow)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staging-1", "production-1", "staging-2"}, executionNames(executions))
+	assert.Contains(t, out.String(), "2/3 passed")
+	assert.Contains(t, out.String(), "1m30s")
+}
+
+func TestListWasAppVersionTestedOnEnvironment(t *testing.T) {
+	opt := &largetest.OptionsList{
+		Environment:      "staging",
+		Apps:             []string{"orders:1.0.0"},
+		Output:           topology.OutputJSON,
+		LargeTestOptions: queryOptions(),
+	}
+	out := &bytes.Buffer{}
+
+	executions, err := opt.List(out, fixtureNow)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staging-2"}, executionNames(executions))
+	var printed []sdlc.LargeTestExecution
+	require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Equal(t, []string{"staging-2"}, executionNames(printed))
+
+	opt.Result = "ok"
+	executions, err = opt.List(&bytes.Buffer{}, fixtureNow)
+	require.NoError(t, err)
+	assert.Empty(t, executions)
+}
+
+func TestListByAppNameAndTimeRange(t *testing.T) {
+	opt := &largetest.OptionsList{
+		Apps:             []string{"orders"},
+		Since:            "60h",
+		Until:            "2021-05-01T00:00:00Z",
+		Output:           topology.OutputTable,
+		LargeTestOptions: queryOptions(),
+	}
+
+	executions, err := opt.List(&bytes.Buffer{}, fixtureNow)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staging-1", "production-1"}, executionNames(executions))
+
+	opt.Since = "yesterday"
+	_, err = opt.List(&bytes.Buffer{}, fixtureNow)
+	assert.EqualError(t, err, `invalid --since: "yesterday" is neither RFC3339 time nor duration`)
+}
+
+func TestGetAndDescribe(t *testing.T) {
+	options := queryOptions()
+	options.Namespace = "jx-staging"
+	out := &bytes.Buffer{}
+
+	require.NoError(t, (&largetest.OptionsGet{Output: topology.OutputYAML, LargeTestOptions: options}).Get(out, "staging-1"))
+	assert.Contains(t, out.String(), "name: staging-1")
+	assert.Contains(t, out.String(), "result: Succeeded")
+
+	out.Reset()
+	require.NoError(t, options.Describe(out, "staging-1"))
+	assert.Contains(t, out.String(), "Environment: staging")
+	assert.Contains(t, out.String(), "  - orders.cancel")
+	assert.Contains(t, out.String(), "| search  | 0.1.0   |")
+
+	options.Namespace = ""
+	assert.EqualError(t, options.Describe(out, "staging-1"), "--namespace of large test execution staging-1 is required")
+}
+
+func TestListByLabelSelector(t *testing.T) {
+	opt := &largetest.OptionsList{
+		Selector:         sdlc.LabelCommit + "=abc",
+		Output:           topology.OutputTable,
+		LargeTestOptions: queryOptions(),
+	}
+
+	executions, err := opt.List(&bytes.Buffer{}, fixtureNow)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staging-2"}, executionNames(executions))
+}
@@ -0,0 +1,116 @@
+package largetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+)
+
+func makeMigrateCmd(options *LargeTestOptions) *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:     "migrate",
+		Short:   "fill status of large test executions created before status subresource from spec.result and spec.time, add missing topology hash labels, move reports which are not urls to annotation",
+		Example: "sdlc largetest migrate -n jx-staging --dry-run",
+		Run: func(cmd *cobra.Command, args []string) {
+			_, err := options.Migrate()
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	migrateCmd.Flags().BoolVarP(
+		&options.DryRun, "dry-run", "", false, "only print executions which would be converted",
+	)
+
+	return migrateCmd
+}
+
+// Migrate converts legacy executions and returns how many of them were (or would be in dry run) converted
+func (opt *LargeTestOptions) Migrate() (int, error) {
+	opt.KubeClient, opt.JxClient, opt.LtClient = utils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
+
+	executions, err := opt.LtClient.LargetestV1beta1().LargeTestExecutions(opt.Namespace).List(
+		context.TODO(), metav1.ListOptions{},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("can't list large test executions: %w", err)
+	}
+
+	converted := 0
+	failed := 0
+	for i := range executions.Items {
+		lte := &executions.Items[i]
+		lteLog := log.WithField("name", lte.Name).WithField("ns", lte.Namespace)
+
+		if len(lte.Spec.Topology) == 0 {
+			// schema requires topology, such execution can't be updated and proves nothing
+			lteLog.Warn("large test execution has no topology, it can't be converted, delete it")
+			failed++
+			continue
+		}
+		changed, err := lte.ConvertLegacy()
+		if err != nil {
+			lteLog.WithError(err).Warn("can't convert large test execution")
+			failed++
+			continue
+		}
+		rewritten := addTopologyHash(lte)
+		if lte.ConvertLegacyReport() {
+			lteLog.WithField("report", lte.Annotations[sdlc.AnnotationLegacyReport]).
+				Warn("report is not an url, it is moved to annotation " + sdlc.AnnotationLegacyReport)
+			rewritten = true
+		}
+		if !changed && !rewritten {
+			continue
+		}
+
+		converted++
+		lteLog = lteLog.WithField("result", lte.Status.Result).WithField("started", lte.Status.StartTime)
+		if opt.DryRun {
+			lteLog.Info("large test execution would be converted")
+			continue
+		}
+		client := opt.LtClient.LargetestV1beta1().LargeTestExecutions(lte.Namespace)
+		if rewritten {
+			// status is not updated together with metadata and spec once status subresource is enabled
+			status := lte.Status.DeepCopy()
+			updated, err := client.Update(context.TODO(), lte, metav1.UpdateOptions{})
+			if err != nil {
+				return converted - 1, fmt.Errorf("can't update large test execution %s/%s: %w", lte.Namespace, lte.Name, err)
+			}
+			lte = updated
+			lte.Status = *status
+		}
+		if changed {
+			_, err = client.UpdateStatus(context.TODO(), lte, metav1.UpdateOptions{})
+			if err != nil {
+				return converted - 1, fmt.Errorf("can't update status of large test execution %s/%s: %w", lte.Namespace, lte.Name, err)
+			}
+		}
+		lteLog.Info("large test execution converted")
+	}
+
+	if failed > 0 {
+		return converted, fmt.Errorf("%d large test executions can't be converted", failed)
+	}
+	return converted, nil
+}
+
+// addTopologyHash labels execution created before topology hash label, it returns false when label is up to date
+func addTopologyHash(lte *sdlc.LargeTestExecution) bool {
+	hash := utils.TopologyHash(lte.Spec.Topology)
+	if lte.Labels[sdlc.LabelTopologyHash] == hash {
+		return false
+	}
+	if lte.Labels == nil {
+		lte.Labels = map[string]string{}
+	}
+	lte.Labels[sdlc.LabelTopologyHash] = hash
+	return true
+}
@@ -0,0 +1,170 @@
+package largetest_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcFake "github.com/vitech-team/sdlcctl/client/clientset/versioned/fake"
+	"github.com/vitech-team/sdlcctl/cmd/largetest"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+var legacyTopology = []sdlc.AppVersion{{Name: "orders", Version: "1.0.0"}}
+
+func legacyExecution(name string, result string, timestamp string) *sdlc.LargeTestExecution {
+	return &sdlc.LargeTestExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "jx-staging"},
+		Spec: sdlc.LargeTestExecutionSpec{
+			Environment: "staging", Namespace: "jx-staging", Result: result, Time: timestamp, Topology: legacyTopology,
+		},
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	converted := legacyExecution("converted", "", "")
+	converted.Status.Result = sdlc.ResultFailed
+	converted.Labels = map[string]string{sdlc.LabelTopologyHash: utils.TopologyHash(legacyTopology)}
+
+	ltClient := sdlcFake.NewSimpleClientset(
+		legacyExecution("ok", "ok", "2021-04-20 10:11:12.5 +0300 EEST m=+0.01"),
+		legacyExecution("failed", "failed", "2021-04-21T08:00:00Z"),
+		converted,
+	)
+	options := &largetest.LargeTestOptions{Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   ltClient,
+	}}
+
+	count, err := options.Migrate()
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	lte, err := ltClient.LargetestV1beta1().LargeTestExecutions("jx-staging").Get(context.TODO(), "ok", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, sdlc.ResultSucceeded, lte.Status.Result)
+	require.NotNil(t, lte.Status.StartTime)
+	assert.Equal(t, time.Date(2021, 4, 20, 7, 11, 12, 5e8, time.UTC), lte.Status.StartTime.UTC())
+	require.Len(t, lte.Status.Conditions, 1)
+	assert.Equal(t, sdlc.ConditionCompleted, lte.Status.Conditions[0].Type)
+	assert.Equal(t, metav1.ConditionTrue, lte.Status.Conditions[0].Status)
+
+	lte, err = ltClient.LargetestV1beta1().LargeTestExecutions("jx-staging").Get(context.TODO(), "failed", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, sdlc.ResultFailed, lte.Status.Result)
+	assert.True(t, lte.IsCompleted())
+
+	count, err = options.Migrate()
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestMigrateAddsTopologyHash(t *testing.T) {
+	topology := []sdlc.AppVersion{{Name: "orders", Version: "1.1.0"}, {Name: "billing", Version: "2.0.0"}}
+	unlabeled := legacyExecution("unlabeled", "", "")
+	unlabeled.Spec.Topology = topology
+	unlabeled.Status.Result = sdlc.ResultSucceeded
+	legacy := legacyExecution("legacy", "ok", "")
+	legacy.Spec.Topology = topology
+	ltClient := sdlcFake.NewSimpleClientset(unlabeled, legacy)
+	options := &largetest.LargeTestOptions{Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   ltClient,
+	}}
+
+	count, err := options.Migrate()
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	executions, err := ltClient.LargetestV1beta1().LargeTestExecutions("jx-staging").List(context.TODO(), metav1.ListOptions{
+		LabelSelector: sdlc.LabelTopologyHash + "=" + utils.TopologyHash(topology),
+	})
+	require.NoError(t, err)
+	require.Len(t, executions.Items, 2)
+	for _, lte := range executions.Items {
+		assert.Equal(t, sdlc.ResultSucceeded, lte.Status.Result, lte.Name)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	ltClient := sdlcFake.NewSimpleClientset(legacyExecution("ok", "success", ""))
+	options := &largetest.LargeTestOptions{DryRun: true, Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   ltClient,
+	}}
+
+	count, err := options.Migrate()
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	lte, err := ltClient.LargetestV1beta1().LargeTestExecutions("jx-staging").Get(context.TODO(), "ok", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, lte.Status.Result)
+	assert.Equal(t, sdlc.ResultSucceeded, lte.GetResult())
+}
+
+func TestMigrateReportsUnknownResults(t *testing.T) {
+	ltClient := sdlcFake.NewSimpleClientset(
+		legacyExecution("unknown", "maybe", ""),
+		legacyExecution("ok", "ok", ""),
+	)
+	options := &largetest.LargeTestOptions{Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   ltClient,
+	}}
+
+	count, err := options.Migrate()
+
+	assert.EqualError(t, err, "1 large test executions can't be converted")
+	assert.Equal(t, 1, count)
+}
+
+func TestMigrateMovesInvalidReport(t *testing.T) {
+	relative := legacyExecution("relative", "ok", "")
+	relative.Spec.Report = "rep/ttt/"
+	absolute := legacyExecution("absolute", "ok", "")
+	absolute.Spec.Report = "https://reports.example.com/reports/1"
+	ltClient := sdlcFake.NewSimpleClientset(relative, absolute)
+	options := &largetest.LargeTestOptions{Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   ltClient,
+	}}
+
+	count, err := options.Migrate()
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	lte, err := ltClient.LargetestV1beta1().LargeTestExecutions("jx-staging").Get(context.TODO(), "relative", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, lte.Spec.Report)
+	assert.Equal(t, "rep/ttt/", lte.Annotations[sdlc.AnnotationLegacyReport])
+	assert.Equal(t, sdlc.ResultSucceeded, lte.Status.Result)
+
+	lte, err = ltClient.LargetestV1beta1().LargeTestExecutions("jx-staging").Get(context.TODO(), "absolute", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://reports.example.com/reports/1", lte.Spec.Report)
+	assert.Empty(t, lte.Annotations)
+}
+
+func TestMigrateReportsExecutionsWithoutTopology(t *testing.T) {
+	empty := legacyExecution("empty", "ok", "")
+	empty.Spec.Topology = nil
+	ltClient := sdlcFake.NewSimpleClientset(empty, legacyExecution("ok", "ok", ""))
+	options := &largetest.LargeTestOptions{Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   ltClient,
+	}}
+
+	count, err := options.Migrate()
+
+	assert.EqualError(t, err, "1 large test executions can't be converted")
+	assert.Equal(t, 1, count)
+	lte, err := ltClient.LargetestV1beta1().LargeTestExecutions("jx-staging").Get(context.TODO(), "empty", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, lte.Status.Result)
+}
@@ -0,0 +1,232 @@
+package largetest
+
+import (
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	"io"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"sort"
+	"time"
+)
+
+type OptionsPrune struct {
+	KeepLast              int
+	MaxAge                time.Duration
+	KeepCurrentSuccessful bool
+	*LargeTestOptions
+}
+
+// PruneCandidate is a large test execution selected for deletion
+type PruneCandidate struct {
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Result    sdlc.Result `json:"result"`
+	Started   metav1.Time `json:"started"`
+	Reason    string      `json:"reason"`
+	// job which has run the execution in the cluster, it is deleted together with the execution
+	job *types.NamespacedName
+}
+
+func makePruneCmd(options *LargeTestOptions) *cobra.Command {
+	opt := &OptionsPrune{LargeTestOptions: options}
+
+	pruneCmd := &cobra.Command{
+		Use:     "prune",
+		Short:   "delete outdated large test executions",
+		Example: "sdlc largetest prune --keep-last 5 --max-age 720h --keep-current-successful --dry-run",
+		Run: func(cmd *cobra.Command, args []string) {
+			_, err := opt.Prune(os.Stdout, time.Now())
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	pruneCmd.Flags().IntVarP(
+		&opt.KeepLast, "keep-last", "", 0, "number of the latest executions kept per distinct topology in every namespace, 0 keeps all",
+	)
+	pruneCmd.Flags().DurationVarP(
+		&opt.MaxAge, "max-age", "", 0, "executions started earlier are deleted, e.g. 720h, 0 disables age limit",
+	)
+	pruneCmd.Flags().BoolVarP(
+		&opt.KeepCurrentSuccessful, "keep-current-successful", "", false,
+		"keep every successful execution of the topology currently described by helmfiles",
+	)
+	pruneCmd.Flags().BoolVarP(
+		&opt.DryRun, "dry-run", "", false, "only print executions which would be deleted",
+	)
+
+	return pruneCmd
+}
+
+// Prune deletes executions selected by retention policies and prints them, nothing is deleted in dry run
+func (opt *OptionsPrune) Prune(out io.Writer, now time.Time) ([]PruneCandidate, error) {
+	if opt.KeepLast < 0 {
+		return nil, fmt.Errorf("--keep-last must not be negative")
+	}
+	if opt.KeepLast == 0 && opt.MaxAge <= 0 {
+		return nil, fmt.Errorf("at least one of --keep-last or --max-age is required")
+	}
+
+	var currentTopologies map[string]string
+	if opt.KeepCurrentSuccessful {
+		var err error
+		currentTopologies, err = opt.currentTopologies()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opt.KubeClient, opt.JxClient, opt.LtClient = utils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
+	executions, err := opt.LtClient.LargetestV1beta1().LargeTestExecutions(opt.Namespace).List(
+		context.TODO(), metav1.ListOptions{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("can't list large test executions: %w", err)
+	}
+
+	candidates := opt.selectPruned(executions.Items, currentTopologies, now)
+	renderPruneTable(out, candidates, opt.DryRun)
+	if opt.DryRun {
+		return candidates, nil
+	}
+
+	for _, candidate := range candidates {
+		if err = opt.deleteJob(candidate.job); err != nil {
+			return candidates, err
+		}
+		err = opt.LtClient.LargetestV1beta1().LargeTestExecutions(candidate.Namespace).Delete(
+			context.TODO(), candidate.Name, metav1.DeleteOptions{},
+		)
+		if err != nil {
+			return candidates, fmt.Errorf("can't delete large test execution %s/%s: %w", candidate.Namespace, candidate.Name, err)
+		}
+	}
+	log.WithField("deleted", len(candidates)).Info("large test executions pruned")
+	return candidates, nil
+}
+
+// deleteJob deletes job and its pods, job may be already deleted after TTL
+func (opt *OptionsPrune) deleteJob(job *types.NamespacedName) error {
+	if job == nil {
+		return nil
+	}
+	propagation := metav1.DeletePropagationBackground
+	err := opt.KubeClient.BatchV1().Jobs(job.Namespace).Delete(
+		context.TODO(), job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation},
+	)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("can't delete large test job %s: %w", job, err)
+	}
+	return nil
+}
+
+// currentTopologies returns topology key of every environment namespace described by helmfiles
+func (opt *OptionsPrune) currentTopologies() (map[string]string, error) {
+	optionsTopology := topology.OptionsTopology{Options: opt.Options}
+	envs, err := optionsTopology.GetEnvironmentsFromHelmFile(opt.Helmfile, opt.HelmfileDir)
+	if err != nil {
+		return nil, fmt.Errorf("can't read current topology: %w", err)
+	}
+
+	current := map[string]string{}
+	for _, env := range envs {
+		current[env.Spec.Namespace] = utils.TopologyKey(env.Topology)
+	}
+	return current, nil
+}
+
+// selectPruned groups executions by namespace and topology and selects the ones which violate retention policies,
+// running executions and successful executions of the current topology are never selected
+func (opt *OptionsPrune) selectPruned(executions []sdlc.LargeTestExecution, currentTopologies map[string]string, now time.Time) []PruneCandidate {
+	groups := map[string][]*sdlc.LargeTestExecution{}
+	var keys []string
+	for i := range executions {
+		lte := &executions[i]
+		key := lte.Namespace + "/" + utils.TopologyKey(lte.Spec.Topology)
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], lte)
+	}
+	sort.Strings(keys)
+
+	candidates := []PruneCandidate{}
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(i, j int) bool {
+			left, right := group[i].GetStartTime(), group[j].GetStartTime()
+			return right.Before(&left)
+		})
+
+		for index, lte := range group {
+			if result := lte.GetResult(); result == sdlc.ResultRunning || result == sdlc.ResultPending {
+				continue
+			}
+			current, exists := currentTopologies[lte.Namespace]
+			if exists && lte.GetResult() == sdlc.ResultSucceeded && current == utils.TopologyKey(lte.Spec.Topology) {
+				continue
+			}
+
+			started := lte.GetStartTime()
+			reason := ""
+			switch {
+			case opt.MaxAge > 0 && now.Sub(started.Time) > opt.MaxAge:
+				reason = fmt.Sprintf("older than %s", opt.MaxAge)
+			case opt.KeepLast > 0 && index >= opt.KeepLast:
+				reason = fmt.Sprintf("not in last %d of its topology", opt.KeepLast)
+			default:
+				continue
+			}
+
+			candidate := PruneCandidate{
+				Namespace: lte.Namespace,
+				Name:      lte.Name,
+				Result:    lte.GetResult(),
+				Started:   started,
+				Reason:    reason,
+			}
+			if lte.Status.JobName != "" {
+				candidate.job = &types.NamespacedName{Namespace: lte.Spec.Namespace, Name: lte.Status.JobName}
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+func renderPruneTable(out io.Writer, candidates []PruneCandidate, dryRun bool) {
+	action := "deleted"
+	if dryRun {
+		action = "would be deleted"
+	}
+
+	var data [][]string
+	for _, candidate := range candidates {
+		data = append(data, []string{
+			candidate.Namespace,
+			candidate.Name,
+			string(candidate.Result),
+			candidate.Started.UTC().Format(time.RFC3339),
+			candidate.Reason,
+			action,
+		})
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Ns", "Name", "Result", "Started", "Reason", "Action"})
+
+	table.AppendBulk(data)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.Render()
+}
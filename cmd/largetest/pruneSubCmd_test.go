@@ -0,0 +1,120 @@
+package largetest_test
+
+import (
+	"bytes"
+	"context"
+	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	jxFake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcFake "github.com/vitech-team/sdlcctl/client/clientset/versioned/fake"
+	"github.com/vitech-team/sdlcctl/cmd/largetest"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func pruneOptions(dryRun bool, ltClient *sdlcFake.Clientset) *largetest.OptionsPrune {
+	return &largetest.OptionsPrune{
+		KeepLast:              2,
+		MaxAge:                30 * 24 * time.Hour,
+		KeepCurrentSuccessful: true,
+		LargeTestOptions: &largetest.LargeTestOptions{
+			DryRun: dryRun,
+			Options: &utils.Options{
+				Helmfile:    "helmfile.yaml",
+				HelmfileDir: "../topology/testdata/diff/head",
+				KubeClient:  kubeFake.NewSimpleClientset(),
+				JxClient: jxFake.NewSimpleClientset(&jxV1.Environment{
+					ObjectMeta: metav1.ObjectMeta{Name: "staging", Namespace: "jx"},
+					Spec:       jxV1.EnvironmentSpec{Namespace: "jx-staging", Order: 100},
+				}),
+				LtClient: ltClient,
+			},
+		},
+	}
+}
+
+func pruneFixtures() []runtime.Object {
+	return []runtime.Object{
+		execution("old-1", "staging", sdlc.ResultSucceeded, 1, oldTopology),
+		execution("old-2", "staging", sdlc.ResultFailed, 2, oldTopology),
+		execution("old-3", "staging", sdlc.ResultSucceeded, 3, oldTopology),
+		execution("old-40", "staging", sdlc.ResultSucceeded, 40, oldTopology),
+		execution("old-running", "staging", sdlc.ResultRunning, 60, oldTopology),
+		execution("current-50", "staging", sdlc.ResultSucceeded, 50, currentTopology),
+		execution("current-51", "staging", sdlc.ResultFailed, 51, currentTopology),
+	}
+}
+
+func names(ltClient *sdlcFake.Clientset, t *testing.T) []string {
+	executions, err := ltClient.LargetestV1beta1().LargeTestExecutions("jx-staging").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	var result []string
+	for _, lte := range executions.Items {
+		result = append(result, lte.Name)
+	}
+	return result
+}
+
+func TestPrune(t *testing.T) {
+	ltClient := sdlcFake.NewSimpleClientset(pruneFixtures()...)
+
+	candidates, err := pruneOptions(false, ltClient).Prune(&bytes.Buffer{}, fixtureNow)
+
+	require.NoError(t, err)
+	require.Len(t, candidates, 3)
+	assert.Equal(t, "old-3", candidates[0].Name)
+	assert.Equal(t, "not in last 2 of its topology", candidates[0].Reason)
+	assert.Equal(t, "old-40", candidates[1].Name)
+	assert.Equal(t, "older than 720h0m0s", candidates[1].Reason)
+	assert.Equal(t, "current-51", candidates[2].Name)
+	assert.ElementsMatch(t, []string{"old-1", "old-2", "old-running", "current-50"}, names(ltClient, t))
+}
+
+func TestPruneDeletesJobs(t *testing.T) {
+	executions := pruneFixtures()
+	executions[0].(*sdlc.LargeTestExecution).Status.JobName = "old-1-job"
+	executions[2].(*sdlc.LargeTestExecution).Status.JobName = "old-3-job"
+	kubeClient := kubeFake.NewSimpleClientset(
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "old-1-job", Namespace: "jx-staging"}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "old-3-job", Namespace: "jx-staging"}},
+	)
+	options := pruneOptions(false, sdlcFake.NewSimpleClientset(executions...))
+	options.KubeClient = kubeClient
+
+	_, err := options.Prune(&bytes.Buffer{}, fixtureNow)
+
+	require.NoError(t, err)
+	jobs, err := kubeClient.BatchV1().Jobs("jx-staging").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, jobs.Items, 1)
+	assert.Equal(t, "old-1-job", jobs.Items[0].Name)
+}
+
+func TestPruneDryRun(t *testing.T) {
+	ltClient := sdlcFake.NewSimpleClientset(pruneFixtures()...)
+	out := &bytes.Buffer{}
+
+	candidates, err := pruneOptions(true, ltClient).Prune(out, fixtureNow)
+
+	require.NoError(t, err)
+	assert.Len(t, candidates, 3)
+	assert.Contains(t, out.String(), "would be deleted")
+	assert.Len(t, names(ltClient, t), 7)
+}
+
+func TestPruneRequiresPolicy(t *testing.T) {
+	options := pruneOptions(true, sdlcFake.NewSimpleClientset())
+	options.KeepLast = 0
+	options.MaxAge = 0
+
+	_, err := options.Prune(&bytes.Buffer{}, fixtureNow)
+
+	assert.EqualError(t, err, "at least one of --keep-last or --max-age is required")
+}
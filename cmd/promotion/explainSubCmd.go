@@ -0,0 +1,343 @@
+package promotion
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// PromotionExplanationAPIVersion is the apiVersion of `promotion explain` structured output
+	PromotionExplanationAPIVersion = "sdlc.vitechteam.com/v1"
+	// PromotionExplanationKind is the kind of `promotion explain` structured output
+	PromotionExplanationKind = "PromotionExplanation"
+)
+
+type OptionsExplain struct {
+	Env     string
+	Closest int
+	Output  string
+	*PromotionOptions
+}
+
+// PromotionExplanation tells per gated environment why promotion is allowed or not
+type PromotionExplanation struct {
+	APIVersion   string                   `json:"apiVersion"`
+	Kind         string                   `json:"kind"`
+	Environments []EnvironmentExplanation `json:"environments"`
+}
+
+type EnvironmentExplanation struct {
+	Name      string                `json:"name"`
+	Namespace string                `json:"namespace"`
+	Match     string                `json:"match"`
+	Tested    bool                  `json:"tested"`
+	Rules     []RuleResult          `json:"rules"`
+	Upstreams []UpstreamExplanation `json:"upstreams,omitempty"`
+}
+
+// UpstreamExplanation shows what large test executions of upstream environment lack
+type UpstreamExplanation struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Topology is TopologyKey of the topology large tests have to be run on
+	Topology string `json:"topology"`
+	// Untested are apps of promoted topology which no successful execution has tested
+	Untested []sdlc.AppVersion `json:"untested"`
+	// Closest are executions with the fewest differences from promoted topology, the latest first
+	Closest []ClosestExecution `json:"closest"`
+}
+
+type ClosestExecution struct {
+	Name   string      `json:"name"`
+	Suite  string      `json:"suite,omitempty"`
+	Result sdlc.Result `json:"result"`
+	Age    string      `json:"age"`
+	Diff   []AppDiff   `json:"diff"`
+}
+
+// AppDiff is an app whose tested version doesn't match promoted topology
+type AppDiff struct {
+	Name string `json:"name"`
+	// Promoted version, empty for apps which are tested but not promoted
+	Promoted string `json:"promoted,omitempty"`
+	// Tested version, empty for apps which have not been tested
+	Tested string `json:"tested,omitempty"`
+}
+
+func makeExplainCmd(options *PromotionOptions) *cobra.Command {
+	opt := &OptionsExplain{PromotionOptions: options}
+
+	explainCmd := &cobra.Command{
+		Use:   "explain",
+		Short: "explain which apps lack large test evidence and which executions are the closest to promoted topology",
+		Example: "sdlc promotion explain --env production\n" +
+			"sdlc promotion explain --policy promotion-policy.yaml -o yaml",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := opt.Explain(os.Stdout)
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	addPolicyFlags(explainCmd, options)
+	explainCmd.Flags().StringVarP(&opt.Env, "env", "", "", "explain only promotion to this environment")
+	explainCmd.Flags().IntVarP(&opt.Closest, "closest", "", 3, "number of the closest executions shown per upstream")
+	explainCmd.Flags().StringVarP(
+		&opt.Output, "output", "o", topology.OutputTable, "output format: table, json or yaml",
+	)
+
+	return explainCmd
+}
+
+func (opt *OptionsExplain) Explain(out io.Writer) error {
+	if err := topology.ValidateOutputFormat(opt.Output); err != nil {
+		return err
+	}
+	if err := opt.prepare(); err != nil {
+		return err
+	}
+
+	optionsTopology := topology.OptionsTopology{Options: opt.Options}
+	environments, err := optionsTopology.GetComparedTopology()
+	if err != nil {
+		return err
+	}
+
+	filteredEnvs := findEnvWithPromotion(environments, opt.policy.upstreamEnvironments())
+	if err := validatePromotionGraph(filteredEnvs, opt.PromotionOptions); err != nil {
+		return err
+	}
+	explanation, err := opt.explain(filteredEnvs)
+	if err != nil {
+		return err
+	}
+	if opt.Output == topology.OutputTable {
+		renderExplanation(out, explanation)
+		return nil
+	}
+	return topology.WriteStructured(out, explanation, opt.Output)
+}
+
+func (opt *OptionsExplain) explain(filteredEnvs []utils.Environment) (PromotionExplanation, error) {
+	explanation := PromotionExplanation{
+		APIVersion:   PromotionExplanationAPIVersion,
+		Kind:         PromotionExplanationKind,
+		Environments: []EnvironmentExplanation{},
+	}
+	envs := map[string]utils.Environment{}
+	for _, env := range filteredEnvs {
+		envs[env.Name] = env
+	}
+
+	for _, report := range collectTestExecutions(filteredEnvs, opt.PromotionOptions) {
+		if opt.Env != "" && report.Name != opt.Env {
+			continue
+		}
+		envExplanation := EnvironmentExplanation{
+			Name:      report.Name,
+			Namespace: report.Spec.Namespace,
+			Match:     report.Match,
+			Tested:    report.Tested,
+			Rules:     report.Rules,
+		}
+
+		policy := opt.policy.ForEnvironment(report.Name)
+		match := opt.matchOf(policy)
+		gated := utils.ActiveTopology(withoutApps(report.Topology, policy.ExemptApps))
+		for _, name := range report.Upstreams {
+			upstream := envs[name]
+			largeTests, err := opt.GetLargeTestExecutions(upstream, "")
+			if err != nil {
+				return explanation, fmt.Errorf("can't list large test executions in %s: %w", upstream.Spec.Namespace, err)
+			}
+			envExplanation.Upstreams = append(envExplanation.Upstreams,
+				opt.explainUpstream(upstream, gated, largeTests.Items, match))
+		}
+		explanation.Environments = append(explanation.Environments, envExplanation)
+	}
+
+	if opt.Env != "" && len(explanation.Environments) == 0 {
+		return explanation, fmt.Errorf("environment %s is not gated by promotion", opt.Env)
+	}
+	return explanation, nil
+}
+
+func (opt *OptionsExplain) explainUpstream(
+	upstream utils.Environment, promoted []sdlc.AppVersion, executions []sdlc.LargeTestExecution, match topologyMatch,
+) UpstreamExplanation {
+	explanation := UpstreamExplanation{
+		Name:      upstream.Name,
+		Namespace: upstream.Spec.Namespace,
+		Topology:  utils.TopologyKey(promoted),
+		Untested:  []sdlc.AppVersion{},
+		Closest:   []ClosestExecution{},
+	}
+
+	for _, app := range promoted {
+		tested := false
+		for _, lte := range executions {
+			if lte.GetResult() == sdlc.ResultSucceeded && len(diffTopology([]sdlc.AppVersion{app}, lte.Spec.Topology, match, false)) == 0 {
+				tested = true
+				break
+			}
+		}
+		if !tested {
+			explanation.Untested = append(explanation.Untested, sdlc.AppVersion{Name: app.Name, Version: app.Version})
+		}
+	}
+
+	type candidate struct {
+		lte  sdlc.LargeTestExecution
+		diff []AppDiff
+	}
+	var candidates []candidate
+	for _, lte := range executions {
+		tested := withoutApps(lte.Spec.Topology, match.exemptApps)
+		candidates = append(candidates, candidate{lte: lte, diff: diffTopology(promoted, tested, match, match.mode == MatchExact)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if len(candidates[i].diff) != len(candidates[j].diff) {
+			return len(candidates[i].diff) < len(candidates[j].diff)
+		}
+		iStarted, jStarted := candidates[i].lte.GetStartTime(), candidates[j].lte.GetStartTime()
+		return jStarted.Before(&iStarted)
+	})
+
+	now := opt.now()
+	for i, candidate := range candidates {
+		if i == opt.Closest {
+			break
+		}
+		explanation.Closest = append(explanation.Closest, ClosestExecution{
+			Name:   candidate.lte.Name,
+			Suite:  candidate.lte.Spec.Suite,
+			Result: candidate.lte.GetResult(),
+			Age:    now.Sub(completedAt(&candidate.lte)).Round(time.Second).String(),
+			Diff:   candidate.diff,
+		})
+	}
+	return explanation
+}
+
+// diffTopology returns promoted apps which tested topology doesn't match by matching mode,
+// extra tested apps are differences only when extra is set
+func diffTopology(promoted []sdlc.AppVersion, tested []sdlc.AppVersion, match topologyMatch, extra bool) []AppDiff {
+	diff := []AppDiff{}
+	testedVersions := map[string]string{}
+	for _, app := range utils.ActiveTopology(tested) {
+		testedVersions[app.Name] = app.Version
+	}
+
+	promotedApps := map[string]bool{}
+	for _, app := range utils.ActiveTopology(promoted) {
+		promotedApps[app.Name] = true
+		testedVersion, ok := testedVersions[app.Name]
+		satisfied := testedVersion == app.Version
+		if ok && match.mode == MatchSemver {
+			satisfied, _ = utils.SatisfiesConstraint(match.constraints[app.Name], app.Version, testedVersion)
+		}
+		if !ok || !satisfied {
+			diff = append(diff, AppDiff{Name: app.Name, Promoted: app.Version, Tested: testedVersion})
+		}
+	}
+	if extra {
+		for name, version := range testedVersions {
+			if !promotedApps[name] {
+				diff = append(diff, AppDiff{Name: name, Tested: version})
+			}
+		}
+	}
+
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i].Name < diff[j].Name
+	})
+	return diff
+}
+
+func renderExplanation(out io.Writer, explanation PromotionExplanation) {
+	for _, env := range explanation.Environments {
+		state := "tested"
+		if !env.Tested {
+			state = "not tested"
+		}
+		fmt.Fprintf(out, "Environment %s (%s): %s, match %s\n", env.Name, env.Namespace, state, env.Match)
+		for _, rule := range env.Rules {
+			outcome := "PASS"
+			if !rule.Passed {
+				outcome = "FAIL"
+			}
+			name := rule.Rule
+			if rule.Subject != "" {
+				name += " " + rule.Subject
+			}
+			if rule.Upstream != "" {
+				name += " [" + rule.Upstream + "]"
+			}
+			fmt.Fprintf(out, "  %s %s: %s\n", outcome, name, rule.Message)
+		}
+
+		for _, upstream := range env.Upstreams {
+			fmt.Fprintf(out, "Upstream %s (%s), large tests have to be run on %s\n", upstream.Name, upstream.Namespace, upstream.Topology)
+			var untested []string
+			for _, app := range upstream.Untested {
+				untested = append(untested, app.Name+":"+app.Version)
+			}
+			if len(untested) > 0 {
+				fmt.Fprintf(out, "  apps without successful execution: %s\n", strings.Join(untested, ", "))
+			}
+			if len(upstream.Closest) == 0 {
+				fmt.Fprintf(out, "  no large test executions in %s\n", upstream.Namespace)
+				continue
+			}
+			renderClosestTable(out, upstream.Closest)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+func renderClosestTable(out io.Writer, executions []ClosestExecution) {
+	var data [][]string
+	for _, lte := range executions {
+		data = append(data, []string{lte.Name, lte.Suite, string(lte.Result), lte.Age, describeDiff(lte.Diff)})
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Execution", "Suite", "Result", "Age", "Diff"})
+	// diffs are long, wrapping makes them hard to read
+	table.SetAutoWrapText(false)
+
+	table.AppendBulk(data)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.Render()
+}
+
+func describeDiff(diff []AppDiff) string {
+	if len(diff) == 0 {
+		return "matches"
+	}
+	var changes []string
+	for _, app := range diff {
+		switch {
+		case app.Tested == "":
+			changes = append(changes, fmt.Sprintf("%s %s not tested", app.Name, app.Promoted))
+		case app.Promoted == "":
+			changes = append(changes, fmt.Sprintf("%s %s not promoted", app.Name, app.Tested))
+		default:
+			changes = append(changes, fmt.Sprintf("%s tested %s, promoted %s", app.Name, app.Tested, app.Promoted))
+		}
+	}
+	return strings.Join(changes, ", ")
+}
@@ -0,0 +1,107 @@
+package promotion
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcFake "github.com/vitech-team/sdlcctl/client/clientset/versioned/fake"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func explainOptions(t *testing.T, executions ...*sdlc.LargeTestExecution) *OptionsExplain {
+	ltClient := sdlcFake.NewSimpleClientset()
+	for _, lte := range executions {
+		require.NoError(t, ltClient.Tracker().Add(lte))
+	}
+	return &OptionsExplain{
+		Closest: 2,
+		Output:  topology.OutputTable,
+		PromotionOptions: &PromotionOptions{
+			Match:    MatchSubset,
+			Evidence: EvidenceLatest,
+			Now:      func() time.Time { return time.Date(2021, 4, 20, 10, 0, 0, 0, time.UTC) },
+			Options:  &utils.Options{KubeClient: kubeFake.NewSimpleClientset(), LtClient: ltClient},
+		},
+	}
+}
+
+func TestExplainListsUntestedAppsAndClosestExecutions(t *testing.T) {
+	closest := suiteExecution("closest", "e2e", sdlc.ResultFailed, 2,
+		[]sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.1.0"}})
+	older := suiteExecution("older", "smoke", sdlc.ResultSucceeded, 30,
+		[]sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.0.0"}})
+	unrelated := suiteExecution("unrelated", "", sdlc.ResultSucceeded, 1,
+		[]sdlc.AppVersion{{Name: "search", Version: "0.1.0"}})
+	opt := explainOptions(t, closest, older, unrelated)
+
+	explanation, err := opt.explain(promotedEnvs())
+
+	require.NoError(t, err)
+	assert.Equal(t, PromotionExplanationAPIVersion, explanation.APIVersion)
+	assert.Equal(t, PromotionExplanationKind, explanation.Kind)
+	require.Len(t, explanation.Environments, 1)
+	production := explanation.Environments[0]
+	assert.Equal(t, "production", production.Name)
+	assert.False(t, production.Tested)
+	assert.Equal(t, MatchSubset, production.Match)
+	require.Len(t, production.Upstreams, 1)
+	upstream := production.Upstreams[0]
+	assert.Equal(t, "staging", upstream.Name)
+	assert.Equal(t, "billing:2.0.0,orders:1.1.0", upstream.Topology)
+	assert.Equal(t, []sdlc.AppVersion{{Name: "orders", Version: "1.1.0"}}, upstream.Untested)
+	assert.Equal(t, []ClosestExecution{
+		{Name: "closest", Suite: "e2e", Result: sdlc.ResultFailed, Age: "2h0m0s", Diff: []AppDiff{}},
+		{Name: "older", Suite: "smoke", Result: sdlc.ResultSucceeded, Age: "30h0m0s",
+			Diff: []AppDiff{{Name: "orders", Promoted: "1.1.0", Tested: "1.0.0"}}},
+	}, upstream.Closest)
+
+	out := &bytes.Buffer{}
+	renderExplanation(out, explanation)
+	assert.Contains(t, out.String(), "Environment production (jx-production): not tested, match subset")
+	assert.Contains(t, out.String(), "FAIL evidence [staging]: large test execution jx-staging/closest failed")
+	assert.Contains(t, out.String(), "apps without successful execution: orders:1.1.0")
+	assert.Contains(t, out.String(), "orders tested 1.0.0, promoted 1.1.0")
+}
+
+func TestExplainExactMatchShowsExtraApps(t *testing.T) {
+	superset := suiteExecution("superset", "", sdlc.ResultSucceeded, 1,
+		[]sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.1.0"}, {Name: "search", Version: "0.1.0"}})
+	opt := explainOptions(t, superset)
+	opt.Match = MatchExact
+
+	explanation, err := opt.explain(promotedEnvs())
+
+	require.NoError(t, err)
+	upstream := explanation.Environments[0].Upstreams[0]
+	assert.Empty(t, upstream.Untested)
+	assert.Equal(t, []AppDiff{{Name: "search", Tested: "0.1.0"}}, upstream.Closest[0].Diff)
+	assert.Equal(t, "search 0.1.0 not promoted", describeDiff(upstream.Closest[0].Diff))
+}
+
+func TestExplainWithoutExecutions(t *testing.T) {
+	opt := explainOptions(t)
+
+	explanation, err := opt.explain(promotedEnvs())
+
+	require.NoError(t, err)
+	upstream := explanation.Environments[0].Upstreams[0]
+	assert.Empty(t, upstream.Closest)
+	assert.Len(t, upstream.Untested, 2)
+	out := &bytes.Buffer{}
+	renderExplanation(out, explanation)
+	assert.Contains(t, out.String(), "no large test executions in jx-staging")
+}
+
+func TestExplainUnknownEnvironment(t *testing.T) {
+	opt := explainOptions(t)
+	opt.Env = "qa"
+
+	_, err := opt.explain(promotedEnvs())
+
+	assert.EqualError(t, err, "environment qa is not gated by promotion")
+}
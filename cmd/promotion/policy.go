@@ -0,0 +1,338 @@
+package promotion
+
+import (
+	"fmt"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"time"
+)
+
+const (
+	// PromotionPolicyAPIVersion is the apiVersion of promotion-policy.yaml
+	PromotionPolicyAPIVersion = "sdlc.vitechteam.com/v1"
+	// PromotionPolicyKind is the kind of promotion-policy.yaml
+	PromotionPolicyKind = "PromotionPolicy"
+)
+
+// Rules of promotion policy reported by `promotion valid`
+const (
+	// RuleEvidence requires successful execution which has tested the topology
+	RuleEvidence = "evidence"
+	// RuleRequiredSuite requires successful execution of the suite which has tested the topology
+	RuleRequiredSuite = "required-suite"
+	// RuleMinSuccessfulRuns requires several successful executions
+	RuleMinSuccessfulRuns = "min-successful-runs"
+	// RuleMaxEvidenceAge rejects successful executions which have completed too long ago
+	RuleMaxEvidenceAge = "max-evidence-age"
+	// RuleExemptApps excludes changes of apps from gating
+	RuleExemptApps = "exempt-apps"
+	// RuleUngatedBumps lets promote semver bumps without large test executions
+	RuleUngatedBumps = "ungated-bumps"
+	// RuleUpstreams requires upstream environments of promotion graph to exist
+	RuleUpstreams = "upstreams"
+)
+
+// PromotionPolicy declares what evidence of large testing is required to promote to an environment
+type PromotionPolicy struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Default applies to environments which are not listed in Environments
+	Default *EnvironmentPolicy `json:"default,omitempty"`
+	// Environments are policies by name of the environment changes are promoted to
+	Environments map[string]EnvironmentPolicy `json:"environments,omitempty"`
+}
+
+// EnvironmentPolicy is promotion policy of a single environment
+type EnvironmentPolicy struct {
+	// RequiredSuites must each have a successful execution, any suite is accepted when it is empty
+	RequiredSuites []string `json:"requiredSuites,omitempty"`
+	// MinSuccessfulRuns is the number of successful executions of any suite, 1 when it is not set
+	MinSuccessfulRuns int `json:"minSuccessfulRuns,omitempty"`
+	// MaxEvidenceAge is how long ago successful execution may have completed, e.g. 72h
+	MaxEvidenceAge *metav1.Duration `json:"maxEvidenceAge,omitempty"`
+	// ExemptApps are apps whose changes don't require large test executions
+	ExemptApps []string `json:"exemptApps,omitempty"`
+	// SkipPatchBumps lets promote patch bumps without large test executions
+	SkipPatchBumps bool `json:"skipPatchBumps,omitempty"`
+	// Match is topology matching mode, --match is used when it is empty
+	Match string `json:"match,omitempty"`
+	// VersionConstraints of apps for semver matching, they override --version-constraint
+	VersionConstraints map[string]string `json:"versionConstraints,omitempty"`
+	// Upstreams are environments whose executions are evidence for promotion, e.g. qa and perf for production;
+	// every upstream has to satisfy the policy, the previous environment by order is the upstream when it is empty
+	Upstreams []string `json:"upstreams,omitempty"`
+}
+
+// RuleResult is pass or fail of a policy rule for an environment
+type RuleResult struct {
+	Rule string `json:"rule"`
+	// Subject is the suite checked by suite specific rules
+	Subject string `json:"subject,omitempty"`
+	// Upstream is the environment whose executions have been checked
+	Upstream string `json:"upstream,omitempty"`
+	Passed   bool   `json:"passed"`
+	Message  string `json:"message"`
+}
+
+// EnvironmentReport is the outcome of promotion policy rules of an environment
+type EnvironmentReport struct {
+	utils.Environment
+	// Match is topology matching mode executions have been matched by
+	Match string `json:"match"`
+	// Upstreams are environments whose executions have been checked
+	Upstreams []string     `json:"upstreams,omitempty"`
+	Rules     []RuleResult `json:"rules"`
+}
+
+// LoadPromotionPolicy reads promotion policy file either in json or yaml, unknown fields are rejected
+func LoadPromotionPolicy(path string) (*PromotionPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read promotion policy %s: %w", path, err)
+	}
+
+	policy := &PromotionPolicy{}
+	err = yaml.UnmarshalStrict(data, policy)
+	if err != nil {
+		return nil, fmt.Errorf("can't read promotion policy %s: %w", path, err)
+	}
+	if policy.Kind != PromotionPolicyKind || policy.APIVersion != PromotionPolicyAPIVersion {
+		return nil, fmt.Errorf("%s is not a promotion policy, expected %s %s but found %s %s",
+			path, PromotionPolicyAPIVersion, PromotionPolicyKind, policy.APIVersion, policy.Kind)
+	}
+
+	policies := map[string]EnvironmentPolicy{}
+	for name, envPolicy := range policy.Environments {
+		policies["environment "+name] = envPolicy
+		for _, upstream := range envPolicy.Upstreams {
+			if upstream == name {
+				return nil, fmt.Errorf("environment %s policy of %s has itself as upstream", name, path)
+			}
+		}
+	}
+	if policy.Default != nil {
+		policies["default"] = *policy.Default
+	}
+	for name, envPolicy := range policies {
+		if envPolicy.MinSuccessfulRuns < 0 {
+			return nil, fmt.Errorf("%s policy of %s has negative minSuccessfulRuns", name, path)
+		}
+		if envPolicy.MaxEvidenceAge != nil && envPolicy.MaxEvidenceAge.Duration <= 0 {
+			return nil, fmt.Errorf("%s policy of %s has non-positive maxEvidenceAge", name, path)
+		}
+		if envPolicy.Match != "" {
+			if err := validMatch(envPolicy.Match); err != nil {
+				return nil, fmt.Errorf("%s policy of %s: %w", name, path, err)
+			}
+		}
+		for app, constraint := range envPolicy.VersionConstraints {
+			if err := utils.ValidateConstraint(constraint); err != nil {
+				return nil, fmt.Errorf("%s policy of %s, app %s: %w", name, path, app, err)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// ForEnvironment returns policy of the environment changes are promoted to, default upstreams are not applied
+// to upstreams of promotion graph, they would be upstreams of themselves
+func (policy *PromotionPolicy) ForEnvironment(name string) EnvironmentPolicy {
+	if policy == nil {
+		return EnvironmentPolicy{}
+	}
+	if envPolicy, ok := policy.Environments[name]; ok {
+		return envPolicy
+	}
+	if policy.Default == nil {
+		return EnvironmentPolicy{}
+	}
+	envPolicy := *policy.Default
+	if policy.upstreamEnvironments()[name] {
+		envPolicy.Upstreams = nil
+	}
+	return envPolicy
+}
+
+// upstreamEnvironments are environments which are upstreams of promotion graph
+func (policy *PromotionPolicy) upstreamEnvironments() map[string]bool {
+	upstreams := map[string]bool{}
+	if policy == nil {
+		return upstreams
+	}
+	envPolicies := []EnvironmentPolicy{}
+	for _, envPolicy := range policy.Environments {
+		envPolicies = append(envPolicies, envPolicy)
+	}
+	if policy.Default != nil {
+		envPolicies = append(envPolicies, *policy.Default)
+	}
+	for _, envPolicy := range envPolicies {
+		for _, upstream := range envPolicy.Upstreams {
+			upstreams[upstream] = true
+		}
+	}
+	return upstreams
+}
+
+func (report *EnvironmentReport) add(rule string, subject string, passed bool, message string) {
+	report.Rules = append(report.Rules, RuleResult{Rule: rule, Subject: subject, Passed: passed, Message: message})
+}
+
+// Passed is true when every rule has passed
+func (report *EnvironmentReport) Passed() bool {
+	for _, rule := range report.Rules {
+		if !rule.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// FailedRules are messages of rules which have not passed
+func (report *EnvironmentReport) FailedRules() []string {
+	var failed []string
+	for _, rule := range report.Rules {
+		if !rule.Passed {
+			failed = append(failed, rule.Message)
+		}
+	}
+	return failed
+}
+
+// evaluateEvidence checks matched executions of upstream environment against evidence rules of policy,
+// executions of required suites are selected separately
+func (opt *PromotionOptions) evaluateEvidence(
+	report *EnvironmentReport, upstream utils.Environment, executions []sdlc.LargeTestExecution, policy EnvironmentPolicy,
+) {
+	now := opt.now()
+	first := len(report.Rules)
+	defer func() {
+		for i := first; i < len(report.Rules); i++ {
+			report.Rules[i].Upstream = upstream.Name
+		}
+	}()
+
+	if len(policy.RequiredSuites) == 0 {
+		opt.checkEvidence(report, RuleEvidence, "", upstream, executions, policy, now)
+	}
+	for _, suite := range policy.RequiredSuites {
+		opt.checkEvidence(report, RuleRequiredSuite, suite, upstream, ofSuite(executions, suite), policy, now)
+	}
+
+	if policy.MinSuccessfulRuns > 1 {
+		successful := 0
+		for _, lte := range executions {
+			if lte.GetResult() == sdlc.ResultSucceeded && isFresh(&lte, policy, now) {
+				successful++
+			}
+		}
+		report.add(RuleMinSuccessfulRuns, "", successful >= policy.MinSuccessfulRuns,
+			fmt.Sprintf("%d of %d required successful large test executions", successful, policy.MinSuccessfulRuns))
+	}
+}
+
+func (opt *PromotionOptions) checkEvidence(
+	report *EnvironmentReport, rule string, suite string, upstream utils.Environment,
+	executions []sdlc.LargeTestExecution, policy EnvironmentPolicy, now time.Time,
+) {
+	subject := "large test execution"
+	if suite != "" {
+		subject = "large test execution of suite " + suite
+	}
+
+	evidence := selectEvidence(executions, opt.Evidence)
+	if evidence == nil {
+		report.add(rule, suite, false, fmt.Sprintf("no %s found in %s", subject, upstream.Spec.Namespace))
+		return
+	}
+
+	name := evidence.Namespace + "/" + evidence.Name
+	result := evidence.GetResult()
+	// the first rejected execution explains why environment is not tested
+	if report.Execution == "" || (result != sdlc.ResultSucceeded && report.Result == sdlc.ResultSucceeded) {
+		report.Execution, report.Result = name, result
+	}
+
+	switch result {
+	case sdlc.ResultSucceeded:
+		report.add(rule, suite, true, fmt.Sprintf("%s %s succeeded", subject, name))
+	case sdlc.ResultFailed:
+		report.add(rule, suite, false, fmt.Sprintf("%s %s failed", subject, name))
+		return
+	case sdlc.ResultError:
+		report.add(rule, suite, false, fmt.Sprintf("%s %s errored", subject, name))
+		return
+	default:
+		report.add(rule, suite, false, fmt.Sprintf("%s %s has not completed", subject, name))
+		return
+	}
+
+	if policy.MaxEvidenceAge != nil {
+		age := now.Sub(completedAt(evidence)).Round(time.Second)
+		report.add(RuleMaxEvidenceAge, suite, isFresh(evidence, policy, now),
+			fmt.Sprintf("%s %s completed %s ago, at most %s is allowed", subject, name, age, policy.MaxEvidenceAge.Duration))
+	}
+}
+
+func ofSuite(executions []sdlc.LargeTestExecution, suite string) []sdlc.LargeTestExecution {
+	var filtered []sdlc.LargeTestExecution
+	for _, lte := range executions {
+		if lte.Spec.Suite == suite {
+			filtered = append(filtered, lte)
+		}
+	}
+	return filtered
+}
+
+func completedAt(lte *sdlc.LargeTestExecution) time.Time {
+	if lte.Status.CompletionTime != nil {
+		return lte.Status.CompletionTime.Time
+	}
+	return lte.GetStartTime().Time
+}
+
+func isFresh(lte *sdlc.LargeTestExecution, policy EnvironmentPolicy, now time.Time) bool {
+	if policy.MaxEvidenceAge == nil {
+		return true
+	}
+	return now.Sub(completedAt(lte)) <= policy.MaxEvidenceAge.Duration
+}
+
+// withoutApps removes exempt apps from topology
+func withoutApps(topology []sdlc.AppVersion, apps []string) []sdlc.AppVersion {
+	if len(apps) == 0 {
+		return topology
+	}
+	exempt := map[string]bool{}
+	for _, app := range apps {
+		exempt[app] = true
+	}
+	var filtered []sdlc.AppVersion
+	for _, app := range topology {
+		if !exempt[app.Name] {
+			filtered = append(filtered, app)
+		}
+	}
+	return filtered
+}
+
+func exemptChanges(topology []sdlc.AppVersion, apps []string) []string {
+	var changed []string
+	for _, app := range topology {
+		if app.State != sdlc.StateSame && len(withoutApps([]sdlc.AppVersion{app}, apps)) == 0 {
+			changed = append(changed, app.Name)
+		}
+	}
+	return changed
+}
+
+func describeApps(apps []string) string {
+	if len(apps) == 0 {
+		return "no changes"
+	}
+	return "changes of " + strings.Join(apps, ", ")
+}
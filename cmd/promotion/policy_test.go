@@ -0,0 +1,150 @@
+package promotion
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcFake "github.com/vitech-team/sdlcctl/client/clientset/versioned/fake"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+)
+
+func TestLoadPromotionPolicy(t *testing.T) {
+	policy, err := LoadPromotionPolicy("testdata/promotion-policy.yaml")
+
+	require.NoError(t, err)
+	production := policy.ForEnvironment("production")
+	assert.Equal(t, []string{"smoke", "e2e"}, production.RequiredSuites)
+	assert.Equal(t, 2, production.MinSuccessfulRuns)
+	assert.Equal(t, 72*time.Hour, production.MaxEvidenceAge.Duration)
+	assert.Equal(t, []string{"docs"}, production.ExemptApps)
+	assert.True(t, production.SkipPatchBumps)
+	assert.Equal(t, MatchSemver, production.Match)
+	assert.Equal(t, []string{"qa", "perf"}, production.Upstreams)
+	assert.Equal(t, map[string]bool{"qa": true, "perf": true}, policy.upstreamEnvironments())
+	assert.Equal(t, map[string]string{"billing": "~"}, production.VersionConstraints)
+	assert.Equal(t, EnvironmentPolicy{MinSuccessfulRuns: 1}, policy.ForEnvironment("staging"))
+	assert.Equal(t, EnvironmentPolicy{}, (*PromotionPolicy)(nil).ForEnvironment("production"))
+}
+
+func TestDefaultUpstreamsAreNotAppliedToUpstreams(t *testing.T) {
+	policy := &PromotionPolicy{Default: &EnvironmentPolicy{MinSuccessfulRuns: 2, Upstreams: []string{"qa", "perf"}}}
+
+	assert.Equal(t, []string{"qa", "perf"}, policy.ForEnvironment("production").Upstreams)
+	assert.Equal(t, EnvironmentPolicy{MinSuccessfulRuns: 2}, policy.ForEnvironment("qa"))
+	assert.Equal(t, EnvironmentPolicy{MinSuccessfulRuns: 2}, policy.ForEnvironment("perf"))
+	assert.Equal(t, []string{"qa", "perf"}, policy.Default.Upstreams)
+}
+
+func TestLoadPromotionPolicyRejectsInvalidFile(t *testing.T) {
+	_, err := LoadPromotionPolicy("testdata/unknown-field.yaml")
+	assert.Error(t, err)
+
+	_, err = LoadPromotionPolicy("testdata/missing.yaml")
+	assert.Error(t, err)
+}
+
+func suiteExecution(name string, suite string, result sdlc.Result, completedHoursAgo int, topology []sdlc.AppVersion) *sdlc.LargeTestExecution {
+	lte := executionWithResult(name, result, completedHoursAgo)
+	lte.Spec.Suite = suite
+	lte.Spec.Topology = topology
+	lte.Labels = map[string]string{sdlc.LabelTopologyHash: utils.TopologyHash(topology)}
+	return lte
+}
+
+func evaluatePolicy(t *testing.T, policy EnvironmentPolicy, envs []utils.Environment, executions ...*sdlc.LargeTestExecution) EnvironmentReport {
+	ltClient := sdlcFake.NewSimpleClientset()
+	for _, lte := range executions {
+		require.NoError(t, ltClient.Tracker().Add(lte))
+	}
+	opt := &PromotionOptions{
+		Match:    MatchExact,
+		Evidence: EvidenceLatest,
+		Now:      func() time.Time { return time.Date(2021, 4, 20, 10, 0, 0, 0, time.UTC) },
+		policy:   &PromotionPolicy{Environments: map[string]EnvironmentPolicy{"production": policy}},
+		Options:  &utils.Options{KubeClient: kubeFake.NewSimpleClientset(), LtClient: ltClient},
+	}
+
+	reports := collectTestExecutions(envs, opt)
+
+	require.Len(t, reports, 1)
+	return reports[0]
+}
+
+func ruleOutcomes(report EnvironmentReport) map[string]bool {
+	outcomes := map[string]bool{}
+	for _, rule := range report.Rules {
+		key := rule.Rule
+		if rule.Subject != "" {
+			key += "/" + rule.Subject
+		}
+		outcomes[key] = rule.Passed
+	}
+	return outcomes
+}
+
+func TestPolicyRequiredSuites(t *testing.T) {
+	tested := []sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.1.0"}}
+	policy := EnvironmentPolicy{RequiredSuites: []string{"smoke", "e2e"}}
+
+	report := evaluatePolicy(t, policy, promotedEnvs(),
+		suiteExecution("smoke", "smoke", sdlc.ResultSucceeded, 1, tested),
+		suiteExecution("e2e", "e2e", sdlc.ResultFailed, 1, tested),
+	)
+
+	assert.False(t, report.Tested)
+	assert.Equal(t, map[string]bool{"required-suite/smoke": true, "required-suite/e2e": false}, ruleOutcomes(report))
+	assert.Equal(t, []string{"large test execution of suite e2e jx-staging/e2e failed"}, report.FailedRules())
+	assert.Equal(t, "jx-staging/e2e", report.Execution)
+	assert.Equal(t, sdlc.ResultFailed, report.Result)
+}
+
+func TestPolicyMinSuccessfulRunsAndEvidenceAge(t *testing.T) {
+	tested := []sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.1.0"}}
+	policy := EnvironmentPolicy{MinSuccessfulRuns: 2, MaxEvidenceAge: &metav1.Duration{Duration: 24 * time.Hour}}
+
+	fresh := evaluatePolicy(t, policy, promotedEnvs(),
+		suiteExecution("first", "", sdlc.ResultSucceeded, 5, tested),
+		suiteExecution("second", "", sdlc.ResultSucceeded, 1, tested),
+	)
+	assert.True(t, fresh.Tested)
+	assert.Equal(t, map[string]bool{"evidence": true, "max-evidence-age": true, "min-successful-runs": true}, ruleOutcomes(fresh))
+
+	stale := evaluatePolicy(t, policy, promotedEnvs(),
+		suiteExecution("first", "", sdlc.ResultSucceeded, 48, tested),
+		suiteExecution("second", "", sdlc.ResultSucceeded, 30, tested),
+	)
+	assert.False(t, stale.Tested)
+	assert.Equal(t, map[string]bool{"evidence": true, "max-evidence-age": false, "min-successful-runs": false}, ruleOutcomes(stale))
+	assert.Contains(t, stale.FailedRules(), "0 of 2 required successful large test executions")
+}
+
+func TestPolicyExemptApps(t *testing.T) {
+	envs := promotedEnvs()
+	envs[1].Topology = append(envs[1].Topology, sdlc.AppVersion{Name: "docs", Version: "3.0.0", State: sdlc.StateUpdated})
+	tested := []sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "docs", Version: "2.9.0"}, {Name: "orders", Version: "1.1.0"}}
+
+	report := evaluatePolicy(t, EnvironmentPolicy{ExemptApps: []string{"docs"}}, envs,
+		suiteExecution("without-docs-update", "", sdlc.ResultSucceeded, 1, tested),
+	)
+
+	assert.True(t, report.Tested)
+	assert.Equal(t, map[string]bool{"exempt-apps": true, "evidence": true}, ruleOutcomes(report))
+	assert.Equal(t, "changes of docs of exempt apps are not gated", report.Rules[0].Message)
+}
+
+func TestPolicySkipPatchBumps(t *testing.T) {
+	envs := promotedEnvs()
+	envs[1].Topology = []sdlc.AppVersion{
+		{Name: "orders", Version: "1.1.1", State: sdlc.StateUpdated, Bump: sdlc.BumpPatch},
+		{Name: "billing", Version: "2.0.0", State: sdlc.StateSame},
+	}
+
+	report := evaluatePolicy(t, EnvironmentPolicy{SkipPatchBumps: true}, envs)
+
+	assert.True(t, report.Tested)
+	assert.Equal(t, map[string]bool{"ungated-bumps": true}, ruleOutcomes(report))
+}
@@ -12,14 +12,61 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"os"
 	"sort"
+	"strings"
+	"time"
+)
+
+// Matching of environment topology with topology of large test executions
+const (
+	// MatchExact requires the same deployed apps, executions are found by topology hash label
+	MatchExact = "exact"
+	// MatchSubset requires every deployed app to be tested, executions may have tested more apps
+	MatchSubset = "subset"
+	// MatchSemver requires every deployed app to be tested with a version satisfying its constraint,
+	// apps without constraint must have been tested with the same version
+	MatchSemver = "semver"
+)
+
+// validMatch checks matching mode of flag or policy
+func validMatch(match string) error {
+	switch match {
+	case MatchExact, MatchSubset, MatchSemver:
+		return nil
+	}
+	return fmt.Errorf("unsupported match %q, expected %s, %s or %s", match, MatchExact, MatchSubset, MatchSemver)
+}
+
+// topologyMatch is how executions are matched with environment topology
+type topologyMatch struct {
+	mode        string
+	exemptApps  []string
+	constraints map[string]string
+}
+
+// Evidence policies which decide what successful execution of matched executions is required
+const (
+	// EvidenceLatest requires the latest completed execution to be successful
+	EvidenceLatest = "latest"
+	// EvidenceAny accepts any successful execution, even if later ones have failed
+	EvidenceAny = "any"
 )
 
 type PromotionOptions struct {
+	UngatedBumps []string
+	Match        string
+	// VersionConstraints are semver constraints of apps by name for semver matching
+	VersionConstraints map[string]string
+	Evidence           string
+	// PolicyFile is promotion-policy.yaml with rules per environment
+	PolicyFile string
+	// Now is the clock evidence age is measured by, time.Now by default
+	Now    func() time.Time
+	policy *PromotionPolicy
 	*utils.Options
 }
 
 func NewPromotionCmd(rootOpts *utils.Options) (*cobra.Command, *PromotionOptions) {
-	options := &PromotionOptions{rootOpts}
+	options := &PromotionOptions{Options: rootOpts}
 
 	command := &cobra.Command{
 		Use:     "promotion",
@@ -37,16 +84,64 @@ func NewPromotionCmd(rootOpts *utils.Options) (*cobra.Command, *PromotionOptions
 		Run: func(cmd *cobra.Command, args []string) {
 			err := options.Validate()
 			if err != nil {
+				log.Error(err.Error())
 				os.Exit(1)
 			}
 		},
 	}
 
+	addPolicyFlags(validate, options)
+
 	command.AddCommand(validate)
+	command.AddCommand(makeExplainCmd(options))
 
 	return command, options
 }
 
+// addPolicyFlags adds flags of promotion rules shared by valid and explain
+func addPolicyFlags(command *cobra.Command, options *PromotionOptions) {
+	command.Flags().StringSliceVarP(
+		&options.UngatedBumps,
+		"ungated-bumps",
+		"",
+		nil,
+		"comma-separated semver bumps (e.g. patch,prerelease) which don't require large test executions when they are the only changes",
+	)
+	command.Flags().StringVarP(
+		&options.Match,
+		"match",
+		"",
+		MatchSubset,
+		"topology matching: exact looks up executions by "+sdlc.LabelTopologyHash+" label "+
+			"(run 'sdlc largetest migrate' to label old executions), subset scans every execution in namespace, "+
+			"semver accepts tested versions satisfying --version-constraint",
+	)
+	command.Flags().StringToStringVarP(
+		&options.VersionConstraints,
+		"version-constraint",
+		"",
+		nil,
+		"semver constraints of apps for semver matching, e.g. orders=~,billing=>=2.0.0 <3.0.0; "+
+			"~ and ^ are relative to the promoted version, apps without constraint require the same version",
+	)
+
+	command.Flags().StringVarP(
+		&options.Evidence,
+		"evidence",
+		"",
+		EvidenceLatest,
+		"which matched execution must be successful: latest completed one or any",
+	)
+	command.Flags().StringVarP(
+		&options.PolicyFile,
+		"policy",
+		"",
+		"",
+		"promotion-policy.yaml with required suites, minimum successful runs, maximum evidence age, "+
+			"exempt apps and patch bumps skipping per environment",
+	)
+}
+
 var log = logrus.New()
 
 func init() {
@@ -57,49 +152,265 @@ func init() {
 }
 
 func (opt *PromotionOptions) Validate() error {
+	if err := opt.prepare(); err != nil {
+		return err
+	}
 
 	optionsTopology := topology.OptionsTopology{Options: opt.Options}
 
 	environments, err := optionsTopology.GetComparedTopology()
 
-	if err == nil {
-		filteredEnvs := findEnvWithPromotion(environments)
-		testedEnvs := collectTestExecutions(filteredEnvs, opt)
-		for _, env := range testedEnvs {
-			if env.Tested {
-				log.WithField("env", env.Name).Info("tested")
+	if err != nil {
+		return err
+	}
+
+	filteredEnvs := findEnvWithPromotion(environments, opt.policy.upstreamEnvironments())
+	if err := validatePromotionGraph(filteredEnvs, opt); err != nil {
+		return err
+	}
+	var untested []string
+	for _, report := range collectTestExecutions(filteredEnvs, opt) {
+		states := utils.CountStates(report.Topology)
+		envLog := log.WithField("env", report.Name).
+			WithField("match", report.Match).
+			WithField(string(sdlc.StateAdded), states[sdlc.StateAdded]).
+			WithField(string(sdlc.StateUpdated), states[sdlc.StateUpdated]).
+			WithField(string(sdlc.StateRemoved), states[sdlc.StateRemoved])
+		for _, rule := range report.Rules {
+			ruleLog := envLog.WithField("rule", rule.Rule)
+			if rule.Subject != "" {
+				ruleLog = ruleLog.WithField("suite", rule.Subject)
+			}
+			if rule.Upstream != "" {
+				ruleLog = ruleLog.WithField("upstream", rule.Upstream)
+			}
+			if rule.Passed {
+				ruleLog.Info(rule.Message)
 			} else {
-				log.WithField("env", env.Name).Error("no large test executions found")
-				return fmt.Errorf("no large test executions found for environment %s namespace %s", env.Name, env.Spec.Namespace)
+				ruleLog.Error(rule.Message)
 			}
 		}
-		log.Info("all changes are tested")
+		if report.Tested {
+			envLog.Info("tested")
+		} else {
+			untested = append(untested, fmt.Sprintf("environment %s namespace %s: %s",
+				report.Name, report.Spec.Namespace, strings.Join(report.FailedRules(), ", ")))
+		}
+	}
+	if len(untested) > 0 {
+		return fmt.Errorf("promotion policy is not satisfied for %s, run 'sdlc promotion explain' for details",
+			strings.Join(untested, "; "))
 	}
+	log.Info("all changes are tested")
+	return nil
+}
 
-	return err
+// prepare validates flags and loads promotion policy
+func (opt *PromotionOptions) prepare() error {
+	for _, bump := range opt.UngatedBumps {
+		switch sdlc.Bump(bump) {
+		case sdlc.BumpMajor, sdlc.BumpMinor, sdlc.BumpPatch, sdlc.BumpPrerelease:
+		default:
+			return fmt.Errorf("unsupported ungated bump %q, expected major, minor, patch or prerelease", bump)
+		}
+	}
+	if err := validMatch(opt.Match); err != nil {
+		return fmt.Errorf("--match: %w", err)
+	}
+	for app, constraint := range opt.VersionConstraints {
+		if err := utils.ValidateConstraint(constraint); err != nil {
+			return fmt.Errorf("--version-constraint of %s: %w", app, err)
+		}
+	}
+	if opt.Evidence != EvidenceLatest && opt.Evidence != EvidenceAny {
+		return fmt.Errorf("unsupported --evidence %q, expected %s or %s", opt.Evidence, EvidenceLatest, EvidenceAny)
+	}
+	if opt.PolicyFile != "" {
+		policy, err := LoadPromotionPolicy(opt.PolicyFile)
+		if err != nil {
+			return err
+		}
+		opt.policy = policy
+	}
+	return nil
 }
 
-func collectTestExecutions(filteredEnvs []utils.Environment, opt *PromotionOptions) []utils.Environment {
-	var testedEnvs []utils.Environment
+func collectTestExecutions(filteredEnvs []utils.Environment, opt *PromotionOptions) []EnvironmentReport {
+	upstreamOnly := opt.policy.upstreamEnvironments()
+	var reports []EnvironmentReport
 	for i, env := range filteredEnvs {
-		if i > 0 {
-			previousEnvironment := filteredEnvs[i-1]
-			largeTests, err := opt.GetLargeTestExecutions(previousEnvironment)
+		policy := opt.policy.ForEnvironment(env.Name)
+		if !isGated(filteredEnvs, i, policy, upstreamOnly) {
+			continue
+		}
+		match := opt.matchOf(policy)
+		report := EnvironmentReport{Environment: env, Match: match.mode}
+
+		gated := env
+		gated.Topology = withoutApps(env.Topology, policy.ExemptApps)
+		if len(policy.ExemptApps) > 0 {
+			report.add(RuleExemptApps, "", true,
+				describeApps(exemptChanges(env.Topology, policy.ExemptApps))+" of exempt apps are not gated")
+		}
+		if bumps := opt.ungatedBumps(policy); utils.OnlyBumps(gated.Topology, bumps) {
+			report.add(RuleUngatedBumps, "", true,
+				fmt.Sprintf("only %v semver bumps, large test executions are not required", bumps))
+			report.Tested = true
+			reports = append(reports, report)
+			continue
+		}
+
+		upstreams, err := upstreamsOf(filteredEnvs, i, policy)
+		if err != nil {
+			report.add(RuleUpstreams, "", false, err.Error())
+		}
+		for _, upstream := range upstreams {
+			report.Upstreams = append(report.Upstreams, upstream.Name)
+			largeTests, err := opt.GetLargeTestExecutions(upstream, selector(gated, match))
 			if err != nil {
-				log.WithField("env", env.Name).WithError(err).Error("can't check large tests")
+				report.add(RuleEvidence, "", false, fmt.Sprintf("can't check large tests in %s: %s", upstream.Spec.Namespace, err))
+				report.Rules[len(report.Rules)-1].Upstream = upstream.Name
+				continue
+			}
+			matchedLargeTest := findLargeTestExecution(gated, largeTests, match)
+			opt.evaluateEvidence(&report, upstream, matchedLargeTest, policy)
+		}
+		report.Tested = report.Passed()
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// isGated is false for the first environment and upstreams which changes are not promoted to,
+// unless policy names their upstreams
+func isGated(envs []utils.Environment, i int, policy EnvironmentPolicy, upstreams map[string]bool) bool {
+	if len(policy.Upstreams) > 0 {
+		return true
+	}
+	return i > 0 && (!upstreams[envs[i].Name] || isPromoted(envs[i]))
+}
+
+// validatePromotionGraph rejects environments which are their own upstreams directly or through other upstreams,
+// their promotion could never be proven
+func validatePromotionGraph(envs []utils.Environment, opt *PromotionOptions) error {
+	upstreamOnly := opt.policy.upstreamEnvironments()
+	graph := map[string][]string{}
+	for i, env := range envs {
+		policy := opt.policy.ForEnvironment(env.Name)
+		if !isGated(envs, i, policy, upstreamOnly) {
+			continue
+		}
+		// unknown upstreams are reported by upstreams rule
+		upstreams, _ := upstreamsOf(envs, i, policy)
+		for _, upstream := range upstreams {
+			graph[env.Name] = append(graph[env.Name], upstream.Name)
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("promotion graph has a cycle: %s -> %s", strings.Join(path, " -> "), name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, upstream := range graph[name] {
+			if err := visit(upstream); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, env := range envs {
+		if err := visit(env.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upstreamsOf returns environments whose executions are evidence for promotion to envs[i],
+// explicit upstreams of policy or the previous environment by order
+func upstreamsOf(envs []utils.Environment, i int, policy EnvironmentPolicy) ([]utils.Environment, error) {
+	if len(policy.Upstreams) == 0 {
+		return envs[i-1 : i], nil
+	}
+
+	var upstreams []utils.Environment
+	for _, name := range policy.Upstreams {
+		found := false
+		for _, env := range envs {
+			if env.Name == name {
+				upstreams = append(upstreams, env)
+				found = true
+				break
 			}
-			matchedLargeTest := findLargeTestExecution(env, largeTests)
-			env.Tested = len(matchedLargeTest) > 0
-			testedEnvs = append(testedEnvs, env)
+		}
+		if !found {
+			return upstreams, fmt.Errorf("upstream environment %s of %s is not found", name, envs[i].Name)
 		}
 	}
-	return testedEnvs
+	return upstreams, nil
 }
 
-func (opt *PromotionOptions) GetLargeTestExecutions(env utils.Environment) (*sdlc.LargeTestExecutionList, error) {
+// ungatedBumps are --ungated-bumps and patch when policy skips patch bumps
+func (opt *PromotionOptions) ungatedBumps(policy EnvironmentPolicy) []sdlc.Bump {
+	var bumps []sdlc.Bump
+	for _, bump := range opt.UngatedBumps {
+		bumps = append(bumps, sdlc.Bump(bump))
+	}
+	if policy.SkipPatchBumps {
+		bumps = append(bumps, sdlc.BumpPatch)
+	}
+	return bumps
+}
+
+// matchOf returns matching mode of policy or --match, constraints of policy override --version-constraint
+func (opt *PromotionOptions) matchOf(policy EnvironmentPolicy) topologyMatch {
+	match := topologyMatch{mode: opt.Match, exemptApps: policy.ExemptApps, constraints: map[string]string{}}
+	if policy.Match != "" {
+		match.mode = policy.Match
+	}
+	for app, constraint := range opt.VersionConstraints {
+		match.constraints[app] = constraint
+	}
+	for app, constraint := range policy.VersionConstraints {
+		match.constraints[app] = constraint
+	}
+	return match
+}
+
+func (opt *PromotionOptions) now() time.Time {
+	if opt.Now == nil {
+		return time.Now()
+	}
+	return opt.Now()
+}
+
+// selector narrows listed executions to the exact topology of env, subset matching has to scan all of them
+// as well as exact matching with exempt apps, because labels are hashes of the whole tested topology
+func selector(env utils.Environment, match topologyMatch) string {
+	if match.mode != MatchExact || len(match.exemptApps) > 0 {
+		return ""
+	}
+	return sdlc.LabelTopologyHash + "=" + utils.TopologyHash(env.Topology)
+}
+
+func (opt *PromotionOptions) GetLargeTestExecutions(env utils.Environment, selector string) (*sdlc.LargeTestExecutionList, error) {
 	opt.KubeClient, opt.JxClient, opt.LtClient = utils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
 	largeTestRuns, err := opt.LtClient.LargetestV1beta1().LargeTestExecutions(env.Spec.Namespace).List(
-		context.TODO(), metav1.ListOptions{},
+		context.TODO(), metav1.ListOptions{LabelSelector: selector},
 	)
 	if err != nil {
 		return nil, err
@@ -107,16 +418,59 @@ func (opt *PromotionOptions) GetLargeTestExecutions(env utils.Environment) (*sdl
 	return largeTestRuns, err
 }
 
-func findLargeTestExecution(env utils.Environment, largeTests *sdlc.LargeTestExecutionList) []sdlc.LargeTestExecution {
+// findLargeTestExecution returns executions which have tested env topology, exempt apps are ignored
+func findLargeTestExecution(env utils.Environment, largeTests *sdlc.LargeTestExecutionList, match topologyMatch) []sdlc.LargeTestExecution {
 	var results []sdlc.LargeTestExecution
+	key := utils.TopologyKey(env.Topology)
 	for _, lte := range largeTests.Items {
-		if matched(env.Topology, lte.Spec.Topology) {
-			results = append(results, lte)
+		switch match.mode {
+		case MatchExact:
+			// guards against stale labels of executions whose topology has been edited
+			if utils.TopologyKey(withoutApps(lte.Spec.Topology, match.exemptApps)) == key {
+				results = append(results, lte)
+			}
+		case MatchSemver:
+			if matchedConstraints(utils.ActiveTopology(env.Topology), utils.ActiveTopology(lte.Spec.Topology), match.constraints) {
+				results = append(results, lte)
+			}
+		default:
+			if matched(utils.ActiveTopology(env.Topology), lte.Spec.Topology) {
+				results = append(results, lte)
+			}
 		}
 	}
 	return results
 }
 
+// selectEvidence picks successful execution required by evidence policy, otherwise the execution which
+// has been rejected, so its result can be reported; it is nil when there are no executions
+func selectEvidence(executions []sdlc.LargeTestExecution, evidence string) *sdlc.LargeTestExecution {
+	sorted := append([]sdlc.LargeTestExecution(nil), executions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		iStarted, jStarted := sorted[i].GetStartTime(), sorted[j].GetStartTime()
+		return jStarted.Before(&iStarted)
+	})
+
+	var latestCompleted *sdlc.LargeTestExecution
+	for i := range sorted {
+		lte := &sorted[i]
+		if evidence == EvidenceAny && lte.GetResult() == sdlc.ResultSucceeded {
+			return lte
+		}
+		if latestCompleted == nil && lte.IsCompleted() {
+			latestCompleted = lte
+		}
+	}
+	if latestCompleted != nil {
+		return latestCompleted
+	}
+	// only running or pending executions
+	if len(sorted) > 0 {
+		return &sorted[0]
+	}
+	return nil
+}
+
 func matched(top1 []sdlc.AppVersion, top2 []sdlc.AppVersion) bool {
 	for _, e1 := range top1 {
 		if !utils.ContainsVersion(e1, top2) {
@@ -126,18 +480,46 @@ func matched(top1 []sdlc.AppVersion, top2 []sdlc.AppVersion) bool {
 	return true
 }
 
-func findEnvWithPromotion(envs []utils.Environment) []utils.Environment {
+// matchedConstraints checks that every target app has been tested with a version satisfying its constraint
+func matchedConstraints(target []sdlc.AppVersion, tested []sdlc.AppVersion, constraints map[string]string) bool {
+	testedVersions := map[string]string{}
+	for _, app := range tested {
+		testedVersions[app.Name] = app.Version
+	}
+	for _, app := range target {
+		testedVersion, ok := testedVersions[app.Name]
+		if !ok {
+			return false
+		}
+		satisfied, err := utils.SatisfiesConstraint(constraints[app.Name], app.Version, testedVersion)
+		if err != nil || !satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+// findEnvWithPromotion returns environments changes are promoted to and upstreams of promotion graph
+// ordered by Spec.Order, environments of the same order are ordered by name
+func findEnvWithPromotion(envs []utils.Environment, upstreams map[string]bool) []utils.Environment {
 
 	var filtered []utils.Environment
 	for _, env := range envs {
-		if env.Spec.PromotionStrategy != "" && env.Spec.PromotionStrategy != v1.PromotionStrategyTypeNever {
+		if isPromoted(env) || upstreams[env.Name] {
 			filtered = append(filtered, env)
 		}
 	}
 
-	sort.Slice(filtered, func(i, j int) bool {
-		return i > j
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if filtered[i].Spec.Order != filtered[j].Spec.Order {
+			return filtered[i].Spec.Order < filtered[j].Spec.Order
+		}
+		return filtered[i].Name < filtered[j].Name
 	})
 
 	return filtered
 }
+
+func isPromoted(env utils.Environment) bool {
+	return env.Spec.PromotionStrategy != "" && env.Spec.PromotionStrategy != v1.PromotionStrategyTypeNever
+}
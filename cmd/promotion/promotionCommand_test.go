@@ -1,9 +1,16 @@
 package promotion
 
 import (
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcFake "github.com/vitech-team/sdlcctl/client/clientset/versioned/fake"
 	"github.com/vitech-team/sdlcctl/cmd/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
 	"testing"
+	"time"
 )
 
 func TestNewTopologyCmd(t *testing.T) {
@@ -20,3 +27,423 @@ func TestNewTopologyCmd(t *testing.T) {
 	err := cmd.Execute()
 	assert.NoError(t, err)
 }
+
+func TestMatchedIgnoresComparisonState(t *testing.T) {
+	target := []sdlc.AppVersion{
+		{Name: "orders", Version: "1.1.0", State: sdlc.StateUpdated},
+		{Name: "legacy", Version: "0.9.0", State: sdlc.StateRemoved},
+	}
+	tested := []sdlc.AppVersion{
+		{Name: "orders", Version: "1.1.0"},
+		{Name: "billing", Version: "2.0.0"},
+	}
+
+	assert.True(t, matched(utils.ActiveTopology(target), tested))
+	assert.False(t, matched(target, tested))
+}
+
+func TestCollectTestExecutionsSkipsUngatedBumps(t *testing.T) {
+	opt := &PromotionOptions{UngatedBumps: []string{"patch"}, Options: &utils.Options{}}
+	envs := []utils.Environment{
+		{},
+		{Topology: []sdlc.AppVersion{
+			{Name: "orders", Version: "1.0.1", State: sdlc.StateUpdated, Bump: sdlc.BumpPatch},
+			{Name: "billing", Version: "2.0.0", State: sdlc.StateSame},
+		}},
+	}
+
+	tested := collectTestExecutions(envs, opt)
+
+	assert.Len(t, tested, 1)
+	assert.True(t, tested[0].Tested)
+}
+
+func testedExecution(name string, topology []sdlc.AppVersion, labeled bool) *sdlc.LargeTestExecution {
+	lte := &sdlc.LargeTestExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "jx-staging"},
+		Spec:       sdlc.LargeTestExecutionSpec{Environment: "staging", Namespace: "jx-staging", Topology: topology},
+	}
+	if labeled {
+		lte.Labels = map[string]string{sdlc.LabelTopologyHash: utils.TopologyHash(topology)}
+	}
+	lte.SetResult(sdlc.ResultSucceeded, metav1.NewTime(time.Date(2021, 4, 20, 10, 0, 0, 0, time.UTC)))
+	return lte
+}
+
+func executionWithResult(name string, result sdlc.Result, startedHoursAgo int) *sdlc.LargeTestExecution {
+	lte := testedExecution(name, []sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.1.0"}}, true)
+	lte.Status = sdlc.LargeTestExecutionStatus{}
+	lte.SetResult(result, metav1.NewTime(time.Date(2021, 4, 20, 10, 0, 0, 0, time.UTC).Add(-time.Duration(startedHoursAgo)*time.Hour)))
+	return lte
+}
+
+func promotedEnvs() []utils.Environment {
+	return []utils.Environment{
+		{Environment: v1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: "staging"},
+			Spec:       v1.EnvironmentSpec{Namespace: "jx-staging"},
+		}},
+		{
+			Environment: v1.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: "production"},
+				Spec:       v1.EnvironmentSpec{Namespace: "jx-production"},
+			},
+			Topology: []sdlc.AppVersion{
+				{Name: "orders", Version: "1.1.0", State: sdlc.StateUpdated},
+				{Name: "billing", Version: "2.0.0", State: sdlc.StateSame},
+				{Name: "legacy", Version: "0.9.0", State: sdlc.StateRemoved},
+			},
+		},
+	}
+}
+
+func TestCollectTestExecutionsMatching(t *testing.T) {
+	exact := []sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.1.0"}}
+	superset := append([]sdlc.AppVersion{{Name: "search", Version: "0.1.0"}}, exact...)
+	patched := []sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.1.4"}}
+	nextMinor := []sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.2.0"}}
+
+	tests := map[string]struct {
+		match       string
+		constraints map[string]string
+		executions  []*sdlc.LargeTestExecution
+		tested      bool
+	}{
+		"exact finds labeled execution": {
+			match:      MatchExact,
+			executions: []*sdlc.LargeTestExecution{testedExecution("exact", exact, true)},
+			tested:     true,
+		},
+		"exact ignores unlabeled execution": {
+			match:      MatchExact,
+			executions: []*sdlc.LargeTestExecution{testedExecution("exact", exact, false)},
+			tested:     false,
+		},
+		"exact ignores superset": {
+			match:      MatchExact,
+			executions: []*sdlc.LargeTestExecution{testedExecution("superset", superset, true)},
+			tested:     false,
+		},
+		"subset accepts superset": {
+			match:      MatchSubset,
+			executions: []*sdlc.LargeTestExecution{testedExecution("superset", superset, false)},
+			tested:     true,
+		},
+		"subset ignores other versions": {
+			match:      MatchSubset,
+			executions: []*sdlc.LargeTestExecution{testedExecution("patched", patched, false)},
+			tested:     false,
+		},
+		"semver accepts version satisfying constraint": {
+			match:       MatchSemver,
+			constraints: map[string]string{"orders": "~"},
+			executions:  []*sdlc.LargeTestExecution{testedExecution("patched", patched, false)},
+			tested:      true,
+		},
+		"semver rejects version outside constraint": {
+			match:       MatchSemver,
+			constraints: map[string]string{"orders": "~"},
+			executions:  []*sdlc.LargeTestExecution{testedExecution("next-minor", nextMinor, false)},
+			tested:      false,
+		},
+		"semver requires same version without constraint": {
+			match:      MatchSemver,
+			executions: []*sdlc.LargeTestExecution{testedExecution("patched", patched, false)},
+			tested:     false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ltClient := sdlcFake.NewSimpleClientset()
+			for _, lte := range test.executions {
+				_ = ltClient.Tracker().Add(lte)
+			}
+			opt := &PromotionOptions{Match: test.match, VersionConstraints: test.constraints, Evidence: EvidenceLatest, Options: &utils.Options{
+				KubeClient: kubeFake.NewSimpleClientset(),
+				LtClient:   ltClient,
+			}}
+
+			tested := collectTestExecutions(promotedEnvs(), opt)
+
+			assert.Len(t, tested, 1)
+			assert.Equal(t, test.tested, tested[0].Tested)
+			assert.Equal(t, test.match, tested[0].Match)
+		})
+	}
+}
+
+func TestPolicyOverridesMatch(t *testing.T) {
+	patched := []sdlc.AppVersion{{Name: "billing", Version: "2.0.0"}, {Name: "orders", Version: "1.1.4"}}
+	policy := EnvironmentPolicy{Match: MatchSemver, VersionConstraints: map[string]string{"orders": "^"}}
+
+	report := evaluatePolicy(t, policy, promotedEnvs(), suiteExecution("patched", "", sdlc.ResultSucceeded, 1, patched))
+
+	assert.True(t, report.Tested)
+	assert.Equal(t, MatchSemver, report.Match)
+}
+
+func TestValidateRejectsInvalidConstraint(t *testing.T) {
+	opt := &PromotionOptions{
+		Match: MatchSemver, Evidence: EvidenceLatest, VersionConstraints: map[string]string{"orders": "about 1.2"}, Options: &utils.Options{},
+	}
+
+	assert.Error(t, opt.Validate())
+}
+
+func TestValidateRejectsUnknownMatch(t *testing.T) {
+	opt := &PromotionOptions{Match: "fuzzy", Options: &utils.Options{}}
+
+	assert.EqualError(t, opt.Validate(), `--match: unsupported match "fuzzy", expected exact, subset or semver`)
+}
+
+func TestCollectTestExecutionsEvidence(t *testing.T) {
+	tests := map[string]struct {
+		evidence   string
+		executions []*sdlc.LargeTestExecution
+		tested     bool
+		execution  string
+		result     sdlc.Result
+	}{
+		"failed execution is not evidence": {
+			evidence:   EvidenceAny,
+			executions: []*sdlc.LargeTestExecution{executionWithResult("failed", sdlc.ResultFailed, 1)},
+			result:     sdlc.ResultFailed,
+			execution:  "jx-staging/failed",
+		},
+		"errored execution is reported": {
+			evidence:   EvidenceLatest,
+			executions: []*sdlc.LargeTestExecution{executionWithResult("errored", sdlc.ResultError, 1)},
+			result:     sdlc.ResultError,
+			execution:  "jx-staging/errored",
+		},
+		"latest requires the latest completed execution to succeed": {
+			evidence: EvidenceLatest,
+			executions: []*sdlc.LargeTestExecution{
+				executionWithResult("green", sdlc.ResultSucceeded, 3),
+				executionWithResult("red", sdlc.ResultFailed, 2),
+				executionWithResult("running", sdlc.ResultRunning, 1),
+			},
+			result:    sdlc.ResultFailed,
+			execution: "jx-staging/red",
+		},
+		"latest accepts green rerun": {
+			evidence: EvidenceLatest,
+			executions: []*sdlc.LargeTestExecution{
+				executionWithResult("red", sdlc.ResultFailed, 2),
+				executionWithResult("green", sdlc.ResultSucceeded, 1),
+			},
+			tested:    true,
+			result:    sdlc.ResultSucceeded,
+			execution: "jx-staging/green",
+		},
+		"any accepts earlier green run": {
+			evidence: EvidenceAny,
+			executions: []*sdlc.LargeTestExecution{
+				executionWithResult("green", sdlc.ResultSucceeded, 3),
+				executionWithResult("red", sdlc.ResultFailed, 2),
+			},
+			tested:    true,
+			result:    sdlc.ResultSucceeded,
+			execution: "jx-staging/green",
+		},
+		"running execution is not evidence": {
+			evidence:   EvidenceAny,
+			executions: []*sdlc.LargeTestExecution{executionWithResult("running", sdlc.ResultRunning, 1)},
+			result:     sdlc.ResultRunning,
+			execution:  "jx-staging/running",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ltClient := sdlcFake.NewSimpleClientset()
+			for _, lte := range test.executions {
+				_ = ltClient.Tracker().Add(lte)
+			}
+			opt := &PromotionOptions{Match: MatchExact, Evidence: test.evidence, Options: &utils.Options{
+				KubeClient: kubeFake.NewSimpleClientset(),
+				LtClient:   ltClient,
+			}}
+
+			tested := collectTestExecutions(promotedEnvs(), opt)
+
+			assert.Len(t, tested, 1)
+			assert.Equal(t, test.tested, tested[0].Tested)
+			assert.Equal(t, test.execution, tested[0].Execution)
+			assert.Equal(t, test.result, tested[0].Result)
+		})
+	}
+}
+
+func TestValidateRejectsUnknownEvidence(t *testing.T) {
+	opt := &PromotionOptions{Match: MatchSubset, Evidence: "first", Options: &utils.Options{}}
+
+	assert.EqualError(t, opt.Validate(), `unsupported --evidence "first", expected latest or any`)
+}
+
+func graphEnv(name string, order int32, strategy v1.PromotionStrategyType) utils.Environment {
+	return utils.Environment{
+		Environment: v1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1.EnvironmentSpec{Namespace: "jx-" + name, Order: order, PromotionStrategy: strategy},
+		},
+		Topology: []sdlc.AppVersion{{Name: "orders", Version: "1.1.0", State: sdlc.StateUpdated}},
+	}
+}
+
+func envNames(envs []utils.Environment) []string {
+	var names []string
+	for _, env := range envs {
+		names = append(names, env.Name)
+	}
+	return names
+}
+
+func TestFindEnvWithPromotionOrdersBySpecOrder(t *testing.T) {
+	envs := []utils.Environment{
+		graphEnv("production", 300, v1.PromotionStrategyTypeManual),
+		graphEnv("dev", 0, v1.PromotionStrategyTypeNever),
+		graphEnv("staging", 100, v1.PromotionStrategyTypeAutomatic),
+		graphEnv("preview", 50, v1.PromotionStrategyTypeNever),
+		graphEnv("perf", 100, v1.PromotionStrategyTypeAutomatic),
+	}
+
+	assert.Equal(t, []string{"perf", "staging", "production"}, envNames(findEnvWithPromotion(envs, nil)))
+	assert.Equal(t, []string{"dev", "perf", "staging", "production"},
+		envNames(findEnvWithPromotion(envs, map[string]bool{"dev": true})))
+}
+
+func TestCollectTestExecutionsWithPromotionGraph(t *testing.T) {
+	envs := []utils.Environment{
+		graphEnv("dev", 0, v1.PromotionStrategyTypeNever),
+		graphEnv("qa", 100, v1.PromotionStrategyTypeAutomatic),
+		graphEnv("perf", 200, v1.PromotionStrategyTypeAutomatic),
+		graphEnv("production", 300, v1.PromotionStrategyTypeManual),
+	}
+	tested := []sdlc.AppVersion{{Name: "orders", Version: "1.1.0"}}
+	execution := func(namespace string, result sdlc.Result) *sdlc.LargeTestExecution {
+		lte := executionWithResult("run", result, 1)
+		lte.Namespace = namespace
+		lte.Spec.Namespace = namespace
+		lte.Spec.Topology = tested
+		return lte
+	}
+	ltClient := sdlcFake.NewSimpleClientset()
+	for _, lte := range []*sdlc.LargeTestExecution{
+		execution("jx-dev", sdlc.ResultSucceeded),
+		execution("jx-qa", sdlc.ResultSucceeded),
+		execution("jx-perf", sdlc.ResultFailed),
+	} {
+		require.NoError(t, ltClient.Tracker().Add(lte))
+	}
+	policy := &PromotionPolicy{Environments: map[string]EnvironmentPolicy{
+		"perf":       {Upstreams: []string{"dev"}},
+		"production": {Upstreams: []string{"qa", "perf"}},
+	}}
+	opt := &PromotionOptions{Match: MatchSubset, Evidence: EvidenceLatest, policy: policy, Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   ltClient,
+	}}
+
+	reports := collectTestExecutions(findEnvWithPromotion(envs, policy.upstreamEnvironments()), opt)
+
+	require.Equal(t, []string{"qa", "perf", "production"}, envNames(environmentsOf(reports)))
+	assert.True(t, reports[0].Tested)
+	assert.Equal(t, "dev", reports[0].Rules[0].Upstream)
+	assert.True(t, reports[1].Tested)
+	assert.Equal(t, "dev", reports[1].Rules[0].Upstream)
+	production := reports[2]
+	assert.False(t, production.Tested)
+	require.Len(t, production.Rules, 2)
+	assert.Equal(t, RuleResult{Rule: RuleEvidence, Upstream: "qa", Passed: true,
+		Message: "large test execution jx-qa/run succeeded"}, production.Rules[0])
+	assert.Equal(t, RuleResult{Rule: RuleEvidence, Upstream: "perf", Passed: false,
+		Message: "large test execution jx-perf/run failed"}, production.Rules[1])
+	assert.Equal(t, "jx-perf/run", production.Execution)
+}
+
+func TestCollectTestExecutionsWithDefaultUpstreams(t *testing.T) {
+	envs := []utils.Environment{
+		graphEnv("dev", 0, v1.PromotionStrategyTypeNever),
+		graphEnv("qa", 100, v1.PromotionStrategyTypeAutomatic),
+		graphEnv("production", 300, v1.PromotionStrategyTypeManual),
+	}
+	policy := &PromotionPolicy{Default: &EnvironmentPolicy{Upstreams: []string{"dev"}}}
+	opt := &PromotionOptions{Match: MatchSubset, Evidence: EvidenceLatest, policy: policy, Options: &utils.Options{
+		KubeClient: kubeFake.NewSimpleClientset(),
+		LtClient:   sdlcFake.NewSimpleClientset(),
+	}}
+	filteredEnvs := findEnvWithPromotion(envs, policy.upstreamEnvironments())
+
+	require.NoError(t, validatePromotionGraph(filteredEnvs, opt))
+	reports := collectTestExecutions(filteredEnvs, opt)
+
+	require.Equal(t, []string{"qa", "production"}, envNames(environmentsOf(reports)))
+	assert.Equal(t, []string{"dev"}, reports[0].Upstreams)
+	assert.Equal(t, []string{"dev"}, reports[1].Upstreams)
+}
+
+func TestValidatePromotionGraphRejectsCycles(t *testing.T) {
+	envs := []utils.Environment{
+		graphEnv("qa", 100, v1.PromotionStrategyTypeAutomatic),
+		graphEnv("perf", 200, v1.PromotionStrategyTypeAutomatic),
+		graphEnv("production", 300, v1.PromotionStrategyTypeManual),
+	}
+
+	tests := map[string]struct {
+		policy   *PromotionPolicy
+		expected string
+	}{
+		"named upstreams": {
+			policy: &PromotionPolicy{Environments: map[string]EnvironmentPolicy{
+				"qa":   {Upstreams: []string{"perf"}},
+				"perf": {Upstreams: []string{"qa"}},
+			}},
+			expected: "promotion graph has a cycle: qa -> perf -> qa",
+		},
+		"previous environment by order": {
+			policy: &PromotionPolicy{Environments: map[string]EnvironmentPolicy{
+				"qa": {Upstreams: []string{"perf"}},
+			}},
+			expected: "promotion graph has a cycle: qa -> perf -> qa",
+		},
+		"self upstream": {
+			policy: &PromotionPolicy{Environments: map[string]EnvironmentPolicy{
+				"production": {Upstreams: []string{"production"}},
+			}},
+			expected: "promotion graph has a cycle: production -> production",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opt := &PromotionOptions{policy: test.policy}
+			assert.EqualError(t, validatePromotionGraph(envs, opt), test.expected)
+		})
+	}
+}
+
+func TestCollectTestExecutionsWithUnknownUpstream(t *testing.T) {
+	envs := promotedEnvs()
+	opt := &PromotionOptions{
+		Match:    MatchSubset,
+		Evidence: EvidenceLatest,
+		policy:   &PromotionPolicy{Environments: map[string]EnvironmentPolicy{"production": {Upstreams: []string{"qa"}}}},
+		Options:  &utils.Options{KubeClient: kubeFake.NewSimpleClientset(), LtClient: sdlcFake.NewSimpleClientset()},
+	}
+
+	reports := collectTestExecutions(envs, opt)
+
+	require.Len(t, reports, 1)
+	assert.False(t, reports[0].Tested)
+	assert.Equal(t, []string{"upstream environment qa of production is not found"}, reports[0].FailedRules())
+}
+
+func environmentsOf(reports []EnvironmentReport) []utils.Environment {
+	var envs []utils.Environment
+	for _, report := range reports {
+		envs = append(envs, report.Environment)
+	}
+	return envs
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/vitech-team/sdlcctl/cmd/controller"
+	"github.com/vitech-team/sdlcctl/cmd/largetest"
 	"github.com/vitech-team/sdlcctl/cmd/promotion"
 	"github.com/vitech-team/sdlcctl/cmd/topology"
 	"github.com/vitech-team/sdlcctl/cmd/utils"
@@ -23,6 +25,10 @@ func Main() *cobra.Command {
 	cmd.AddCommand(topologyCmd)
 	promotionCmd, _ := promotion.NewPromotionCmd(&rootOpts)
 	cmd.AddCommand(promotionCmd)
+	largeTestCmd, _ := largetest.NewLargeTestCmd(&rootOpts)
+	cmd.AddCommand(largeTestCmd)
+	controllerCmd, _ := controller.NewControllerCmd(&rootOpts)
+	cmd.AddCommand(controllerCmd)
 
 	return cmd
 }
@@ -0,0 +1,183 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/spf13/cobra"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
+	"os"
+	"strings"
+)
+
+// TopologyCommentMarker is a hidden line which identifies the sticky topology comment between runs
+const TopologyCommentMarker = "<!-- sdlc-topology-comment -->"
+
+type OptionsTopologyComment struct {
+	PullRequest int
+	// ScmClient and Repository are discovered from --gitUrl when not set
+	ScmClient  *scm.Client
+	Repository string
+	*OptionsTopology
+}
+
+func makeCommentCmd(options *OptionsTopology) *cobra.Command {
+	opt := &OptionsTopologyComment{OptionsTopology: options}
+
+	commentCmd := &cobra.Command{
+		Use:     "comment",
+		Short:   "create or update pull request comment with compared topology of every environment",
+		Example: "sdlc topology comment --pr 42 --base-ref main --head-dir .",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := opt.Comment()
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	commentCmd.Flags().IntVar(&opt.PullRequest, "pr", 0, "pull request number")
+	_ = commentCmd.MarkFlagRequired("pr")
+	commentCmd.Flags().StringVar(
+		&opt.Repository, "repository", "", "repository as owner/name, discovered from --gitUrl by default",
+	)
+
+	return commentCmd
+}
+
+func (opt *OptionsTopologyComment) Comment() error {
+	if opt.PullRequest <= 0 {
+		return fmt.Errorf("pull request number must be positive, got %d", opt.PullRequest)
+	}
+
+	envs, err := opt.GetComparedTopology()
+	if err != nil {
+		return err
+	}
+
+	err = opt.discoverScmClient()
+	if err != nil {
+		return err
+	}
+
+	comment, err := UpsertTopologyComment(opt.ScmClient, opt.Repository, opt.PullRequest, RenderTopologyMarkdown(envs))
+	if err != nil {
+		return err
+	}
+
+	log.WithField("pr", opt.PullRequest).WithField("comment", comment.ID).Info("topology comment has been published")
+	return nil
+}
+
+func (opt *OptionsTopologyComment) discoverScmClient() error {
+	if opt.ScmClient != nil && opt.Repository != "" {
+		return nil
+	}
+
+	scmHelper := scmhelpers.Options{
+		Dir:       opt.HelmfileDir,
+		SourceURL: opt.GitUrl,
+	}
+	scmHelper.ScmClient = opt.ScmClient
+	err := scmHelper.Validate()
+	if err != nil {
+		return fmt.Errorf("can't create scm client for %s: %w", opt.GitUrl, err)
+	}
+
+	opt.ScmClient = scmHelper.ScmClient
+	if opt.Repository == "" {
+		opt.Repository = scm.Join(scmHelper.Owner, scmHelper.Repository)
+	}
+	return nil
+}
+
+// UpsertTopologyComment edits the comment marked with TopologyCommentMarker or creates it when the pull request has none,
+// the comment is recreated when scm provider can't edit comments
+func UpsertTopologyComment(client *scm.Client, repo string, pr int, body string) (*scm.Comment, error) {
+	ctx := context.Background()
+	input := &scm.CommentInput{Body: body}
+
+	existing, err := findTopologyComment(ctx, client, repo, pr)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		comment, _, err := client.PullRequests.CreateComment(ctx, repo, pr, input)
+		if err != nil {
+			return nil, fmt.Errorf("can't create comment on %s#%d: %w", repo, pr, err)
+		}
+		return comment, nil
+	}
+
+	comment, _, err := client.PullRequests.EditComment(ctx, repo, pr, existing.ID, input)
+	if err == nil {
+		return comment, nil
+	}
+	if !errors.Is(err, scm.ErrNotSupported) {
+		return nil, fmt.Errorf("can't update comment %d on %s#%d: %w", existing.ID, repo, pr, err)
+	}
+
+	_, err = client.PullRequests.DeleteComment(ctx, repo, pr, existing.ID)
+	if err != nil {
+		return nil, fmt.Errorf("can't delete comment %d on %s#%d: %w", existing.ID, repo, pr, err)
+	}
+	comment, _, err = client.PullRequests.CreateComment(ctx, repo, pr, input)
+	if err != nil {
+		return nil, fmt.Errorf("can't create comment on %s#%d: %w", repo, pr, err)
+	}
+	return comment, nil
+}
+
+func findTopologyComment(ctx context.Context, client *scm.Client, repo string, pr int) (*scm.Comment, error) {
+	opts := scm.ListOptions{Page: 1, Size: 100}
+	for {
+		comments, res, err := client.PullRequests.ListComments(ctx, repo, pr, opts)
+		if err != nil {
+			return nil, fmt.Errorf("can't list comments of %s#%d: %w", repo, pr, err)
+		}
+		for _, comment := range comments {
+			if strings.Contains(comment.Body, TopologyCommentMarker) {
+				return comment, nil
+			}
+		}
+		if res == nil || res.Page.Next == 0 {
+			return nil, nil
+		}
+		opts.Page = res.Page.Next
+	}
+}
+
+// RenderTopologyMarkdown renders changed apps of every environment as markdown tables
+func RenderTopologyMarkdown(envs []sdlcUtils.Environment) string {
+	md := &strings.Builder{}
+	md.WriteString(TopologyCommentMarker + "\n")
+	md.WriteString("### Topology changes\n\n")
+
+	changed := 0
+	for _, env := range envs {
+		if !env.Changed {
+			continue
+		}
+		changed++
+
+		counts := sdlcUtils.CountStates(env.Topology)
+		fmt.Fprintf(md, "#### %s (`%s`)\n\n", env.Name, env.Spec.Namespace)
+		fmt.Fprintf(md, "%d added, %d updated, %d removed\n\n",
+			counts[sdlc.StateAdded], counts[sdlc.StateUpdated], counts[sdlc.StateRemoved])
+		md.WriteString("| App | State | Bump | Now | Was |\n")
+		md.WriteString("|-----|-------|------|-----|-----|\n")
+		for _, row := range topologyChangeRows(env) {
+			md.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		}
+		md.WriteString("\n")
+	}
+
+	if changed == 0 {
+		md.WriteString("No environment topology is changed.\n")
+	}
+	return md.String()
+}
@@ -0,0 +1,118 @@
+package topology_test
+
+import (
+	"context"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
+	k8sV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func commentedEnvironments() []sdlcUtils.Environment {
+	return []sdlcUtils.Environment{
+		{
+			Changed: true,
+			Topology: []sdlc.AppVersion{
+				{Name: "orders", Version: "1.1.0", State: sdlc.StateUpdated, Bump: sdlc.BumpMinor},
+				{Name: "billing", Version: "2.0.0", State: sdlc.StateSame},
+				{Name: "legacy", Version: "0.9.0", State: sdlc.StateRemoved},
+			},
+			PreviousTopology: []sdlc.AppVersion{
+				{Name: "orders", Version: "1.0.0"},
+				{Name: "billing", Version: "2.0.0"},
+				{Name: "legacy", Version: "0.9.0"},
+			},
+			Environment: jxV1.Environment{
+				ObjectMeta: k8sV1.ObjectMeta{Name: "staging"},
+				Spec:       jxV1.EnvironmentSpec{Namespace: "jx-staging"},
+			},
+		},
+		{
+			Topology: []sdlc.AppVersion{
+				{Name: "orders", Version: "1.0.0", State: sdlc.StateSame},
+			},
+			Environment: jxV1.Environment{
+				ObjectMeta: k8sV1.ObjectMeta{Name: "production"},
+				Spec:       jxV1.EnvironmentSpec{Namespace: "jx-production"},
+			},
+		},
+	}
+}
+
+func TestRenderTopologyMarkdown(t *testing.T) {
+	md := topology.RenderTopologyMarkdown(commentedEnvironments())
+
+	assert.Contains(t, md, topology.TopologyCommentMarker)
+	assert.Contains(t, md, "#### staging (`jx-staging`)")
+	assert.Contains(t, md, "0 added, 1 updated, 1 removed")
+	assert.Contains(t, md, "| orders | updated | minor | 1.1.0 | 1.0.0 |")
+	assert.Contains(t, md, "| legacy | removed |  |  | 0.9.0 |")
+	assert.NotContains(t, md, "billing")
+	assert.NotContains(t, md, "production")
+}
+
+func TestRenderTopologyMarkdownWithoutChanges(t *testing.T) {
+	md := topology.RenderTopologyMarkdown(commentedEnvironments()[1:])
+
+	assert.Contains(t, md, "No environment topology is changed.")
+}
+
+func TestUpsertTopologyCommentIsSticky(t *testing.T) {
+	client, data := fake.NewDefault()
+	repo := "vitech-team/environments"
+	_, _, err := client.PullRequests.CreateComment(context.Background(), repo, 7, &scm.CommentInput{Body: "LGTM"})
+	require.NoError(t, err)
+
+	first, err := topology.UpsertTopologyComment(client, repo, 7, topology.TopologyCommentMarker+"\nfirst")
+	require.NoError(t, err)
+	second, err := topology.UpsertTopologyComment(client, repo, 7, topology.TopologyCommentMarker+"\nsecond")
+	require.NoError(t, err)
+
+	comments := data.PullRequestComments[7]
+	require.Len(t, comments, 2)
+	assert.Equal(t, "LGTM", comments[0].Body)
+	assert.Equal(t, topology.TopologyCommentMarker+"\nsecond", comments[1].Body)
+	assert.Equal(t, second.ID, comments[1].ID)
+	assert.NotEqual(t, first.ID, second.ID)
+	assert.Len(t, data.PullRequestCommentsDeleted, 1)
+}
+
+// editablePullRequests edits comments in place, the fake driver doesn't support it
+type editablePullRequests struct {
+	scm.PullRequestService
+	comments map[int][]*scm.Comment
+}
+
+func (s *editablePullRequests) EditComment(_ context.Context, _ string, pr int, id int, input *scm.CommentInput) (*scm.Comment, *scm.Response, error) {
+	for _, comment := range s.comments[pr] {
+		if comment.ID == id {
+			comment.Body = input.Body
+			return comment, nil, nil
+		}
+	}
+	return nil, nil, scm.ErrNotFound
+}
+
+func TestUpsertTopologyCommentEditsInPlace(t *testing.T) {
+	client, data := fake.NewDefault()
+	client.PullRequests = &editablePullRequests{PullRequestService: client.PullRequests, comments: data.PullRequestComments}
+	repo := "vitech-team/environments"
+
+	first, err := topology.UpsertTopologyComment(client, repo, 7, topology.TopologyCommentMarker+"\nfirst")
+	require.NoError(t, err)
+	second, err := topology.UpsertTopologyComment(client, repo, 7, topology.TopologyCommentMarker+"\nsecond")
+	require.NoError(t, err)
+
+	comments := data.PullRequestComments[7]
+	require.Len(t, comments, 1)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, first.ID, comments[0].ID)
+	assert.Equal(t, topology.TopologyCommentMarker+"\nsecond", comments[0].Body)
+	assert.Empty(t, data.PullRequestCommentsDeleted)
+}
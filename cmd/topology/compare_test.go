@@ -0,0 +1,77 @@
+package topology
+
+import (
+	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/stretchr/testify/assert"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
+	"testing"
+)
+
+func envState(namespace string, apps ...sdlc.AppVersion) sdlcUtils.Environment {
+	return sdlcUtils.Environment{
+		Topology:    apps,
+		Environment: jxV1.Environment{Spec: jxV1.EnvironmentSpec{Namespace: namespace}},
+	}
+}
+
+func statesByName(apps []sdlc.AppVersion) map[string]sdlc.State {
+	states := map[string]sdlc.State{}
+	for _, app := range apps {
+		states[app.Name] = app.State
+	}
+	return states
+}
+
+func TestCompareClassifiesApps(t *testing.T) {
+	current := []sdlcUtils.Environment{envState("jx-staging",
+		sdlc.AppVersion{Name: "orders", Version: "1.1.0"},
+		sdlc.AppVersion{Name: "billing", Version: "2.0.0"},
+		sdlc.AppVersion{Name: "search", Version: "0.1.0"},
+	)}
+	base := []sdlcUtils.Environment{envState("jx-staging",
+		sdlc.AppVersion{Name: "orders", Version: "1.0.0"},
+		sdlc.AppVersion{Name: "billing", Version: "2.0.0"},
+		sdlc.AppVersion{Name: "legacy", Version: "0.9.0"},
+	)}
+
+	results := compare(current, base)
+
+	assert.Len(t, results, 1)
+	assert.True(t, results[0].Changed)
+	assert.Equal(t, map[string]sdlc.State{
+		"orders":  sdlc.StateUpdated,
+		"billing": sdlc.StateSame,
+		"search":  sdlc.StateAdded,
+		"legacy":  sdlc.StateRemoved,
+	}, statesByName(results[0].Topology))
+	assert.Equal(t, sdlc.BumpMinor, results[0].Topology[0].Bump)
+	assert.Empty(t, results[0].Topology[1].Bump)
+	assert.Len(t, results[0].PreviousTopology, 3)
+	assert.Len(t, sdlcUtils.ActiveTopology(results[0].Topology), 3)
+}
+
+func TestCompareUnchangedEnvironment(t *testing.T) {
+	apps := []sdlc.AppVersion{{Name: "orders", Version: "1.0.0"}}
+
+	results := compare([]sdlcUtils.Environment{envState("jx-staging", apps...)}, []sdlcUtils.Environment{envState("jx-staging", apps...)})
+
+	assert.False(t, results[0].Changed)
+	assert.Equal(t, sdlc.StateSame, results[0].Topology[0].State)
+}
+
+func TestCompareAddedAndRemovedEnvironments(t *testing.T) {
+	current := []sdlcUtils.Environment{envState("jx-new", sdlc.AppVersion{Name: "orders", Version: "1.0.0"})}
+	base := []sdlcUtils.Environment{envState("jx-old", sdlc.AppVersion{Name: "billing", Version: "2.0.0"})}
+
+	results := compare(current, base)
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, "jx-new", results[0].Spec.Namespace)
+	assert.True(t, results[0].Changed)
+	assert.Equal(t, sdlc.StateAdded, results[0].Topology[0].State)
+	assert.Equal(t, "jx-old", results[1].Spec.Namespace)
+	assert.True(t, results[1].Changed)
+	assert.Equal(t, sdlc.StateRemoved, results[1].Topology[0].State)
+	assert.Empty(t, sdlcUtils.ActiveTopology(results[1].Topology))
+}
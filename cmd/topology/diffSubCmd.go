@@ -0,0 +1,93 @@
+package topology
+
+import (
+	"fmt"
+	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/yamls"
+	"github.com/spf13/cobra"
+	"io"
+	k8sV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"sort"
+)
+
+func makeDiffCmd(opt *OptionsTopology) *cobra.Command {
+	diffCmd := &cobra.Command{
+		Use:     "diff <base dir> <head dir>",
+		Short:   "compare topology of two local helmfile trees without cluster access",
+		Example: "sdlc topology diff ./main ./feature --environments environments.yaml",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := opt.Diff(os.Stdout, args[0], args[1])
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	diffCmd.Flags().StringVarP(
+		&opt.Output, "output", "o", OutputTable, "output format: table, json or yaml",
+	)
+
+	return diffCmd
+}
+
+// Diff compares helmfiles of two directories, environments are named only when --environments file is given
+func (opt *OptionsTopology) Diff(out io.Writer, baseDir string, headDir string) error {
+	if err := ValidateOutputFormat(opt.Output); err != nil {
+		return err
+	}
+
+	var environments []jxV1.Environment
+	if opt.EnvironmentsFile != "" {
+		var err error
+		environments, err = LoadEnvironmentsFile(opt.EnvironmentsFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	baseState, err := GatherTopology(opt.Helmfile, baseDir, opt.HelmfileEnvironment, environments)
+	if err != nil {
+		return err
+	}
+	headState, err := GatherTopology(opt.Helmfile, headDir, opt.HelmfileEnvironment, environments)
+	if err != nil {
+		return err
+	}
+
+	return renderTopology(out, compare(headState, baseState), opt.Output)
+}
+
+// LoadEnvironmentsFile reads either a single Environment or an EnvironmentList sorted by order
+func LoadEnvironmentsFile(path string) ([]jxV1.Environment, error) {
+	typeMeta := k8sV1.TypeMeta{}
+	err := yamls.LoadFile(path, &typeMeta)
+	if err != nil {
+		return nil, fmt.Errorf("can't read environments file %s: %w", path, err)
+	}
+
+	var environments []jxV1.Environment
+	switch typeMeta.Kind {
+	case "Environment":
+		env := jxV1.Environment{}
+		err = yamls.LoadFile(path, &env)
+		environments = append(environments, env)
+	case "EnvironmentList", "List":
+		envs := jxV1.EnvironmentList{}
+		err = yamls.LoadFile(path, &envs)
+		environments = envs.Items
+	default:
+		return nil, fmt.Errorf("environments file %s must contain Environment or EnvironmentList, found kind %q", path, typeMeta.Kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read environments file %s: %w", path, err)
+	}
+
+	sort.SliceStable(environments, func(i, j int) bool {
+		return environments[i].Spec.Order < environments[j].Spec.Order
+	})
+
+	return environments, nil
+}
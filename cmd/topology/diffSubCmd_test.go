@@ -0,0 +1,70 @@
+package topology_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	"testing"
+)
+
+func TestDiffLocalHelmfiles(t *testing.T) {
+	opt := &topology.OptionsTopology{
+		Output:           topology.OutputJSON,
+		EnvironmentsFile: "testdata/diff/environments.yaml",
+		Options:          &utils.Options{Helmfile: "helmfile.yaml"},
+	}
+
+	out := &bytes.Buffer{}
+	err := opt.Diff(out, "testdata/diff/base", "testdata/diff/head")
+	require.NoError(t, err)
+
+	report := topology.TopologyReport{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	require.Len(t, report.Environments, 2)
+
+	envs := map[string]topology.EnvironmentTopology{}
+	for _, env := range report.Environments {
+		envs[env.Name] = env
+	}
+
+	staging := envs["staging"]
+	assert.Equal(t, "jx-staging", staging.Namespace)
+	assert.Equal(t, int32(100), staging.Order)
+	assert.True(t, staging.Changed)
+	assert.Equal(t, []sdlc.AppVersion{
+		{Name: "billing", Version: "2.0.0", State: sdlc.StateSame},
+		{Name: "legacy", Version: "0.9.0", State: sdlc.StateRemoved},
+		{Name: "orders", Version: "1.1.0", State: sdlc.StateUpdated, Bump: sdlc.BumpMinor},
+		{Name: "search", Version: "0.1.0", State: sdlc.StateAdded},
+	}, staging.Topology)
+
+	production := envs["production"]
+	assert.Equal(t, "jx-production", production.Namespace)
+	assert.False(t, production.Changed)
+}
+
+func TestDiffWithoutEnvironmentsFile(t *testing.T) {
+	opt := &topology.OptionsTopology{
+		Output:  topology.OutputTable,
+		Options: &utils.Options{Helmfile: "helmfile.yaml"},
+	}
+
+	out := &bytes.Buffer{}
+	err := opt.Diff(out, "testdata/diff/base", "testdata/diff/head")
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "jx-staging")
+	assert.Contains(t, out.String(), "search")
+	assert.NotContains(t, out.String(), "jx-production")
+}
+
+func TestLoadEnvironmentsFileSortsByOrder(t *testing.T) {
+	envs, err := topology.LoadEnvironmentsFile("testdata/diff/environments.yaml")
+	require.NoError(t, err)
+	require.Len(t, envs, 2)
+	assert.Equal(t, "staging", envs[0].Name)
+	assert.Equal(t, "production", envs[1].Name)
+}
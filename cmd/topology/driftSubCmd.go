@@ -0,0 +1,245 @@
+package topology
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
+	"io"
+	"io/ioutil"
+	k8sV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"sort"
+	"strconv"
+)
+
+type DriftState string
+
+const (
+	DriftVersion   DriftState = "version"
+	DriftMissing   DriftState = "missing"
+	DriftUnmanaged DriftState = "unmanaged"
+
+	helmReleaseSecretType = "helm.sh/release.v1"
+	helmStatusUninstalled = "uninstalled"
+)
+
+// Drift is a difference between helmfile release and Helm 3 release deployed into environment namespace
+type Drift struct {
+	Environment     string     `json:"environment"`
+	Namespace       string     `json:"namespace"`
+	Release         string     `json:"release"`
+	State           DriftState `json:"state"`
+	Version         string     `json:"version,omitempty"`
+	DeployedVersion string     `json:"deployedVersion,omitempty"`
+	Status          string     `json:"status,omitempty"`
+}
+
+// DeployedRelease is the part of Helm 3 release record which is required for drift detection
+type DeployedRelease struct {
+	Name     string
+	Revision int
+	Version  string
+	Status   string
+}
+
+type helmRelease struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	Info    struct {
+		Status string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Version string `json:"version"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+func makeDriftCmd(options *OptionsTopology) *cobra.Command {
+	driftCmd := &cobra.Command{
+		Use:     "drift",
+		Short:   "compare helmfile topology with Helm releases deployed in environment namespaces",
+		Example: "sdlc topology drift -o json",
+		Run: func(cmd *cobra.Command, args []string) {
+			drifts, err := options.Drift(os.Stdout)
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+			if len(drifts) > 0 {
+				log.WithField("drifts", len(drifts)).Error("deployed releases differ from helmfiles")
+				os.Exit(1)
+			}
+		},
+	}
+
+	driftCmd.Flags().StringVarP(
+		&options.Output, "output", "o", OutputTable, "output format: table, json or yaml",
+	)
+
+	return driftCmd
+}
+
+// Drift prints and returns drifts of every environment found in helmfiles
+func (opt *OptionsTopology) Drift(out io.Writer) ([]Drift, error) {
+	if err := ValidateOutputFormat(opt.Output); err != nil {
+		return nil, err
+	}
+
+	environments, err := opt.GetEnvironmentsFromHelmFile(opt.Helmfile, opt.HelmfileDir)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := []Drift{}
+	for _, env := range environments {
+		deployed, err := opt.GetDeployedReleases(env.Spec.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, findDrifts(env, deployed)...)
+	}
+
+	if opt.Output == OutputTable {
+		renderDriftTable(out, drifts)
+		return drifts, nil
+	}
+	return drifts, WriteStructured(out, drifts, opt.Output)
+}
+
+// GetDeployedReleases reads the latest revision of every Helm 3 release stored as secret in namespace
+func (opt *OptionsTopology) GetDeployedReleases(namespace string) (map[string]DeployedRelease, error) {
+	opt.KubeClient, opt.JxClient, opt.LtClient = sdlcUtils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
+
+	secrets, err := opt.KubeClient.CoreV1().Secrets(namespace).List(context.TODO(), k8sV1.ListOptions{
+		LabelSelector: "owner=helm",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't list helm releases in namespace %s: %w", namespace, err)
+	}
+
+	releases := map[string]DeployedRelease{}
+	for _, secret := range secrets.Items {
+		if secret.Type != helmReleaseSecretType {
+			continue
+		}
+		release, err := decodeHelmRelease(secret.Data["release"])
+		if err != nil {
+			return nil, fmt.Errorf("can't decode helm release secret %s/%s: %w", namespace, secret.Name, err)
+		}
+		if release.Name == "" {
+			release.Name = secret.Labels["name"]
+		}
+		if release.Revision == 0 {
+			release.Revision, _ = strconv.Atoi(secret.Labels["version"])
+		}
+		if latest, exists := releases[release.Name]; !exists || latest.Revision < release.Revision {
+			releases[release.Name] = release
+		}
+	}
+	return releases, nil
+}
+
+// decodeHelmRelease decodes release record the same way Helm 3 storage driver does: base64 of gzipped json
+func decodeHelmRelease(data []byte) (DeployedRelease, error) {
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return DeployedRelease{}, err
+	}
+
+	gzipMagic := []byte{0x1f, 0x8b, 0x08}
+	if bytes.HasPrefix(decoded, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return DeployedRelease{}, err
+		}
+		decoded, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return DeployedRelease{}, err
+		}
+	}
+
+	release := helmRelease{}
+	if err = json.Unmarshal(decoded, &release); err != nil {
+		return DeployedRelease{}, err
+	}
+	return DeployedRelease{
+		Name:     release.Name,
+		Revision: release.Version,
+		Version:  release.Chart.Metadata.Version,
+		Status:   release.Info.Status,
+	}, nil
+}
+
+func findDrifts(env sdlcUtils.Environment, deployed map[string]DeployedRelease) []Drift {
+	var drifts []Drift
+	newDrift := func(name string, state DriftState) Drift {
+		return Drift{
+			Environment: env.Name,
+			Namespace:   env.Spec.Namespace,
+			Release:     name,
+			State:       state,
+		}
+	}
+
+	managed := map[string]bool{}
+	for _, app := range env.Topology {
+		managed[app.Name] = true
+		release, exists := deployed[app.Name]
+		switch {
+		case !exists || release.Status == helmStatusUninstalled:
+			drift := newDrift(app.Name, DriftMissing)
+			drift.Version = app.Version
+			drifts = append(drifts, drift)
+		case release.Version != app.Version:
+			drift := newDrift(app.Name, DriftVersion)
+			drift.Version = app.Version
+			drift.DeployedVersion = release.Version
+			drift.Status = release.Status
+			drifts = append(drifts, drift)
+		}
+	}
+
+	var names []string
+	for name := range deployed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !managed[name] && deployed[name].Status != helmStatusUninstalled {
+			drift := newDrift(name, DriftUnmanaged)
+			drift.DeployedVersion = deployed[name].Version
+			drift.Status = deployed[name].Status
+			drifts = append(drifts, drift)
+		}
+	}
+	return drifts
+}
+
+func renderDriftTable(out io.Writer, drifts []Drift) {
+	var data [][]string
+	for _, drift := range drifts {
+		data = append(data, []string{
+			drift.Environment,
+			drift.Release,
+			string(drift.State),
+			drift.Version,
+			drift.DeployedVersion,
+			drift.Status,
+		})
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Env", "Release", "Drift", "Helmfile", "Deployed", "Status"})
+
+	table.AppendBulk(data)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.Render()
+}
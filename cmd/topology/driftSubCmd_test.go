@@ -0,0 +1,84 @@
+package topology_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	jxFake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func helmReleaseSecret(t *testing.T, namespace string, name string, revision int, chartVersion string, status string) *v1.Secret {
+	record := fmt.Sprintf(
+		`{"name":%q,"namespace":%q,"version":%d,"info":{"status":%q},"chart":{"metadata":{"name":%q,"version":%q}}}`,
+		name, namespace, revision, status, name, chartVersion,
+	)
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	_, err := writer.Write([]byte(record))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    name,
+				"version": fmt.Sprintf("%d", revision),
+				"status":  status,
+			},
+		},
+		Type: "helm.sh/release.v1",
+		Data: map[string][]byte{
+			"release": []byte(base64.StdEncoding.EncodeToString(buf.Bytes())),
+		},
+	}
+}
+
+func TestDrift(t *testing.T) {
+	objects := []runtime.Object{
+		// orders has been upgraded by helmfile but an older revision is still stored
+		helmReleaseSecret(t, "jx-staging", "orders", 1, "1.0.0", "superseded"),
+		helmReleaseSecret(t, "jx-staging", "orders", 2, "1.1.0", "deployed"),
+		// billing is deployed with a version different from helmfile
+		helmReleaseSecret(t, "jx-staging", "billing", 3, "1.9.0", "deployed"),
+		// manual helm install which is not described by helmfile
+		helmReleaseSecret(t, "jx-staging", "debug-tools", 1, "0.0.1", "deployed"),
+		helmReleaseSecret(t, "jx-production", "orders", 1, "1.0.0", "deployed"),
+		helmReleaseSecret(t, "jx-production", "billing", 1, "2.0.0", "deployed"),
+	}
+
+	options := &topology.OptionsTopology{
+		Output: topology.OutputJSON,
+		Options: &utils.Options{
+			Helmfile:    "helmfile.yaml",
+			HelmfileDir: "testdata/diff/head",
+			KubeClient:  kubeFake.NewSimpleClientset(objects...),
+			JxClient: jxFake.NewSimpleClientset(&jxV1.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: "staging", Namespace: "jx"},
+				Spec:       jxV1.EnvironmentSpec{Namespace: "jx-staging", Order: 100},
+			}),
+		},
+	}
+
+	drifts, err := options.Drift(&bytes.Buffer{})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []topology.Drift{
+		{Environment: "staging", Namespace: "jx-staging", Release: "billing", State: topology.DriftVersion, Version: "2.0.0", DeployedVersion: "1.9.0", Status: "deployed"},
+		{Environment: "staging", Namespace: "jx-staging", Release: "search", State: topology.DriftMissing, Version: "0.1.0"},
+		{Environment: "staging", Namespace: "jx-staging", Release: "debug-tools", State: topology.DriftUnmanaged, DeployedVersion: "0.0.1", Status: "deployed"},
+	}, drifts)
+}
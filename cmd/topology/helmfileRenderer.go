@@ -0,0 +1,242 @@
+package topology
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/imdario/mergo"
+	"github.com/roboll/helmfile/pkg/environment"
+	"github.com/roboll/helmfile/pkg/helmexec"
+	"github.com/roboll/helmfile/pkg/plugins"
+	"github.com/roboll/helmfile/pkg/remote"
+	"github.com/roboll/helmfile/pkg/state"
+	"github.com/roboll/helmfile/pkg/tmpl"
+	"github.com/variantdev/vals"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const DefaultHelmfileEnvironment = "default"
+
+// helmfileRenderer loads helmfiles the same way helmfile itself does: every file is rendered as a template
+// in two passes using values of the selected environment, `bases:` are merged and nested `helmfiles:` are visited
+type helmfileRenderer struct {
+	environment string
+	logger      *zap.SugaredLogger
+	valsRuntime vals.Evaluator
+	remote      *remote.Remote
+}
+
+// LoadHelmStates renders helmfile in dir and all nested helmfiles for the environment,
+// helmfiles which don't define the environment are skipped like `helmfile -e` does
+func LoadHelmStates(helmfile string, dir string, environmentName string) ([]*state.HelmState, error) {
+	if environmentName == "" {
+		environmentName = DefaultHelmfileEnvironment
+	}
+
+	valsRuntime, err := plugins.ValsInstance()
+	if err != nil {
+		return nil, err
+	}
+	logger := helmexec.NewLogger(os.Stderr, "warn")
+
+	renderer := &helmfileRenderer{
+		environment: environmentName,
+		logger:      logger,
+		valsRuntime: valsRuntime,
+		remote:      remote.NewRemote(logger, "", ioutil.ReadFile, directoryExistsAt, fileExistsAt),
+	}
+	return renderer.visit(nil, filepath.Join(dir, helmfile))
+}
+
+func (r *helmfileRenderer) visit(overrode *environment.Environment, path string) ([]*state.HelmState, error) {
+	st, err := r.render(nil, overrode, filepath.Dir(path), filepath.Base(path), true)
+	if err != nil {
+		var loadErr *state.StateLoadError
+		if errors.As(err, &loadErr) {
+			if _, undefined := loadErr.Cause.(*state.UndefinedEnvError); undefined {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("can't render helmfile %s: %w", path, err)
+	}
+
+	var states []*state.HelmState
+	for _, nested := range st.Helmfiles {
+		if remote.IsRemote(nested.Path) {
+			return nil, fmt.Errorf("remote helmfile %s referenced by %s is not supported", nested.Path, path)
+		}
+
+		var nestedEnv *environment.Environment
+		if len(nested.Environment.OverrideValues) > 0 {
+			storage := state.NewStorage(path, r.logger, filepath.Glob)
+			loader := state.NewEnvironmentValuesLoader(storage, ioutil.ReadFile, r.logger, r.remote)
+			handler := state.MissingFileHandlerError
+			values, err := loader.LoadEnvironmentValues(&handler, nested.Environment.OverrideValues)
+			if err != nil {
+				return nil, fmt.Errorf("can't load values of nested helmfile %s: %w", nested.Path, err)
+			}
+			nestedEnv = &environment.Environment{Name: r.environment, Values: values}
+		}
+
+		// nested paths are already expanded relatively to the parent helmfile directory
+		nestedStates, err := r.visit(nestedEnv, nested.Path)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, nestedStates...)
+	}
+
+	templated, err := st.ExecuteTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("can't execute release templates of %s: %w", path, err)
+	}
+	return append(states, templated), nil
+}
+
+func (r *helmfileRenderer) creator() *state.StateCreator {
+	c := state.NewCreator(
+		r.logger, ioutil.ReadFile, fileExists, filepath.Abs, filepath.Glob, directoryExistsAt,
+		r.valsRuntime, r.getHelm, "", r.remote,
+	)
+	c.DeleteFile = os.Remove
+	c.LoadFile = r.loadFile
+	return c
+}
+
+// loadFile is used by helmfile state creator to load `bases:`
+func (r *helmfileRenderer) loadFile(inherited *environment.Environment, baseDir string, file string, evaluateBases bool) (*state.HelmState, error) {
+	return r.render(inherited, nil, baseDir, file, evaluateBases)
+}
+
+// getHelm is only called to decrypt environment secrets, which requires helm binary anyway
+func (r *helmfileRenderer) getHelm(st *state.HelmState) helmexec.Interface {
+	return helmexec.New(st.DefaultHelmBinary, r.logger, st.HelmDefaults.KubeContext, &helmexec.ShellRunner{
+		Logger: r.logger,
+	})
+}
+
+func (r *helmfileRenderer) render(inherited, overrode *environment.Environment, baseDir string, file string, evaluateBases bool) (*state.HelmState, error) {
+	path := file
+	if !filepath.IsAbs(file) {
+		path = filepath.Join(baseDir, file)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	parts := bytes.Split(normalized, []byte("\n---\n"))
+
+	var finalState *state.HelmState
+	env := inherited
+	for i, part := range parts {
+		id := fmt.Sprintf("%s.part.%d", path, i)
+
+		yamlBuf, err := r.renderTemplate(env, overrode, baseDir, id, part)
+		if err != nil {
+			return nil, fmt.Errorf("error during %s parsing: %w", id, err)
+		}
+
+		merged, err := env.Merge(overrode)
+		if err != nil {
+			return nil, err
+		}
+		currentState, err := r.creator().ParseAndLoad(yamlBuf.Bytes(), baseDir, path, r.environment, evaluateBases, merged)
+		if err != nil {
+			return nil, err
+		}
+		currentState.Helmfiles, err = currentState.ExpandedHelmfiles()
+		if err != nil {
+			return nil, err
+		}
+
+		if finalState == nil {
+			finalState = currentState
+		} else {
+			if err := mergo.Merge(&finalState.ReleaseSetSpec, &currentState.ReleaseSetSpec, mergo.WithOverride); err != nil {
+				return nil, err
+			}
+			finalState.RenderedValues = currentState.RenderedValues
+		}
+		env = &finalState.Env
+	}
+
+	return finalState, nil
+}
+
+// renderTemplate renders helmfile part in two passes, the first one tolerates errors
+// and is used only to evaluate `environments:` values which are available to the second one
+func (r *helmfileRenderer) renderTemplate(inherited, overrode *environment.Environment, baseDir string, id string, content []byte) (*bytes.Buffer, error) {
+	if inherited == nil && overrode == nil {
+		inherited = &environment.Environment{Name: r.environment}
+	}
+
+	initEnv, err := inherited.Merge(overrode)
+	if err != nil {
+		return nil, err
+	}
+
+	renderedEnv := initEnv
+	firstPassRenderer := tmpl.NewFirstPassRenderer(baseDir, state.EnvironmentTemplateData{
+		Environment: *initEnv,
+		Values:      map[string]interface{}{},
+	})
+	firstPass, err := firstPassRenderer.RenderTemplateContentToBuffer(content)
+	if firstPass != nil {
+		c := r.creator()
+		c.Strict = false
+		sanitized := strings.ReplaceAll(firstPass.String(), "<no value>", "")
+		prestate, _ := c.ParseAndLoad([]byte(sanitized), baseDir, id, r.environment, false, initEnv)
+		if prestate != nil {
+			renderedEnv = &prestate.Env
+		}
+	} else if err != nil {
+		r.logger.Debugf("first-pass rendering of %s failed: %v", id, err)
+	}
+
+	finalEnv, err := inherited.Merge(renderedEnv)
+	if err != nil {
+		return nil, err
+	}
+	finalEnv, err = finalEnv.Merge(overrode)
+	if err != nil {
+		return nil, err
+	}
+	values, err := finalEnv.GetMergedValues()
+	if err != nil {
+		return nil, err
+	}
+
+	secondPassRenderer := tmpl.NewFileRenderer(ioutil.ReadFile, baseDir, state.EnvironmentTemplateData{
+		Environment: *finalEnv,
+		Values:      values,
+	})
+	return secondPassRenderer.RenderTemplateContentToBuffer(content)
+}
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func fileExistsAt(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func directoryExistsAt(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsDir()
+}
@@ -0,0 +1,34 @@
+package topology_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"testing"
+)
+
+func TestGatherTopologyFromTemplatedHelmfiles(t *testing.T) {
+	for environment, expected := range map[string][]sdlc.AppVersion{
+		"default": {
+			{Name: "orders", Version: "1.0.0"},
+		},
+		"preview": {
+			{Name: "orders", Version: "2.0.0-rc.1"},
+			{Name: "search", Version: "0.1.0"},
+			{Name: "preview-tools", Version: "0.0.1"},
+		},
+	} {
+		envs, err := topology.GatherTopology("helmfile.yaml", "testdata/templated", environment, nil)
+		require.NoError(t, err, environment)
+		require.Len(t, envs, 1, environment)
+		assert.Equal(t, "jx-staging", envs[0].Spec.Namespace, environment)
+		assert.Equal(t, expected, envs[0].Topology, environment)
+	}
+}
+
+func TestGatherTopologySkipsUndefinedEnvironment(t *testing.T) {
+	envs, err := topology.GatherTopology("helmfile.yaml", "testdata/templated", "production", nil)
+	require.NoError(t, err)
+	assert.Empty(t, envs)
+}
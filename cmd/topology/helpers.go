@@ -6,7 +6,9 @@ import (
 	"github.com/jenkins-x/jx-helpers/pkg/gitclient"
 	"github.com/jenkins-x/jx-helpers/pkg/yamls"
 	"github.com/olekukonko/tablewriter"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
 	"github.com/vitech-team/sdlcctl/apis/topologyrelease/v1beta1"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
 	"io/ioutil"
 	k8sV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"os"
@@ -56,6 +58,40 @@ func determineTagRevision(client gitclient.Interface, gitUrl string, tag string)
 	return revision
 }
 
+// checkoutRef clones gitUrl into a new temporary directory and checks out ref there,
+// ref may be a branch, a tag or a commit SHA, the default branch is kept when ref is empty
+func checkoutRef(client gitclient.Interface, gitUrl string, ref string) (string, error) {
+	gitDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = gitclient.CloneToDir(client, gitUrl, gitDir)
+	if err != nil {
+		_ = os.RemoveAll(gitDir)
+		return "", err
+	}
+
+	if ref == "" {
+		return gitDir, nil
+	}
+
+	_, err = client.Command(gitDir, "checkout", "--quiet", ref)
+	if err != nil {
+		// refs which are not fetched by clone, e.g. pull request heads or unreachable commits
+		_, err = client.Command(gitDir, "fetch", "--quiet", "origin", ref)
+		if err == nil {
+			_, err = client.Command(gitDir, "checkout", "--quiet", "FETCH_HEAD")
+		}
+	}
+	if err != nil {
+		_ = os.RemoveAll(gitDir)
+		return "", fmt.Errorf("can't checkout %s of %s: %w", ref, gitUrl, err)
+	}
+
+	return gitDir, nil
+}
+
 func determineFirstRevision(client gitclient.Interface, gitDir string) string {
 	revision, err := client.Command(gitDir, "rev-list", "--max-parents=0", "HEAD")
 	if err != nil {
@@ -91,6 +127,15 @@ func sortAppVersionsByName(apps []v1beta1.AppVersion) {
 	})
 }
 
+// releaseTopologyHash fingerprints released apps the same way as topology of large test executions
+func releaseTopologyHash(apps []v1beta1.AppVersion) string {
+	var topology []largetestv1beta1.AppVersion
+	for _, app := range apps {
+		topology = append(topology, largetestv1beta1.AppVersion{Name: app.Name, Version: app.Version})
+	}
+	return sdlcUtils.TopologyHash(topology)
+}
+
 func sortAppReleasesByName(apps []AppRelease) {
 	sort.Slice(apps, func(i, j int) bool {
 		switch strings.Compare(apps[i].Name, apps[i].Name) {
@@ -0,0 +1,69 @@
+package topology
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/apis/topologyrelease/v1beta1"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func commitFile(t *testing.T, dir string, content string) string {
+	client := GitClient()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "helmfile.yaml"), []byte(content), 0600))
+	_, err := client.Command(dir, "add", "helmfile.yaml")
+	require.NoError(t, err)
+	_, err = client.Command(dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", content)
+	require.NoError(t, err)
+	sha, err := client.Command(dir, "rev-parse", "HEAD")
+	require.NoError(t, err)
+	return sha
+}
+
+func TestCheckoutRef(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(repoDir)
+
+	client := GitClient()
+	_, err = client.Command(repoDir, "init", "--quiet")
+	require.NoError(t, err)
+
+	firstSha := commitFile(t, repoDir, "first")
+	_, err = client.Command(repoDir, "tag", "v0.0.1-staging")
+	require.NoError(t, err)
+	commitFile(t, repoDir, "second")
+
+	for ref, expected := range map[string]string{
+		"":               "second",
+		"v0.0.1-staging": "first",
+		firstSha:         "first",
+	} {
+		dir, err := checkoutRef(client, repoDir, ref)
+		require.NoError(t, err, ref)
+		content, err := ioutil.ReadFile(filepath.Join(dir, "helmfile.yaml"))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content), ref)
+		_ = os.RemoveAll(dir)
+	}
+
+	_, err = checkoutRef(client, repoDir, "does-not-exist")
+	assert.Error(t, err)
+}
+
+func TestReleaseTopologyHashMatchesTestedTopology(t *testing.T) {
+	released := []v1beta1.AppVersion{
+		{Name: "orders", Version: "1.1.0", GitURL: "https://github.com/vitech-team/orders.git", Revision: "abc"},
+		{Name: "billing", Version: "2.0.0"},
+	}
+	tested := []largetestv1beta1.AppVersion{
+		{Name: "billing", Version: "2.0.0", State: largetestv1beta1.StateSame},
+		{Name: "orders", Version: "1.1.0", State: largetestv1beta1.StateUpdated, Bump: largetestv1beta1.BumpMinor},
+	}
+
+	assert.Equal(t, sdlcUtils.TopologyHash(tested), releaseTopologyHash(released))
+}
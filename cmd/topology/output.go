@@ -0,0 +1,149 @@
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
+	"io"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+
+	TopologyReportAPIVersion = "sdlc.vitechteam.com/v1"
+	TopologyReportKind       = "TopologyReport"
+)
+
+// TopologyReport is the machine-readable form of compared topology
+type TopologyReport struct {
+	APIVersion   string                `json:"apiVersion"`
+	Kind         string                `json:"kind"`
+	Environments []EnvironmentTopology `json:"environments"`
+}
+
+type EnvironmentTopology struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	Order             int32             `json:"order"`
+	PromotionStrategy string            `json:"promotionStrategy,omitempty"`
+	Changed           bool              `json:"changed"`
+	Topology          []sdlc.AppVersion `json:"topology"`
+	PreviousTopology  []sdlc.AppVersion `json:"previousTopology"`
+}
+
+func ValidateOutputFormat(format string) error {
+	switch format {
+	case OutputTable, OutputJSON, OutputYAML:
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q, expected one of: %s", format,
+		strings.Join([]string{OutputTable, OutputJSON, OutputYAML}, ", "))
+}
+
+func NewTopologyReport(envs []sdlcUtils.Environment) TopologyReport {
+	report := TopologyReport{
+		APIVersion:   TopologyReportAPIVersion,
+		Kind:         TopologyReportKind,
+		Environments: []EnvironmentTopology{},
+	}
+	for _, env := range envs {
+		topology := append([]sdlc.AppVersion{}, env.Topology...)
+		previousTopology := append([]sdlc.AppVersion{}, env.PreviousTopology...)
+		sortByName(topology)
+		sortByName(previousTopology)
+		report.Environments = append(report.Environments, EnvironmentTopology{
+			Name:              env.Name,
+			Namespace:         env.Spec.Namespace,
+			Order:             env.Spec.Order,
+			PromotionStrategy: string(env.Spec.PromotionStrategy),
+			Changed:           env.Changed,
+			Topology:          topology,
+			PreviousTopology:  previousTopology,
+		})
+	}
+	return report
+}
+
+func renderTopology(out io.Writer, envs []sdlcUtils.Environment, format string) error {
+	switch format {
+	case OutputJSON, OutputYAML:
+		return WriteStructured(out, NewTopologyReport(envs), format)
+	case OutputTable:
+		renderTopologyTable(out, envs)
+		return nil
+	}
+	return ValidateOutputFormat(format)
+}
+
+// WriteStructured writes value as indented json or yaml
+func WriteStructured(out io.Writer, value interface{}, format string) error {
+	var data []byte
+	var err error
+	if format == OutputYAML {
+		data, err = yaml.Marshal(value)
+	} else {
+		data, err = json.MarshalIndent(value, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+func renderTopologyTable(out io.Writer, envs []sdlcUtils.Environment) {
+	var data = [][]string{}
+
+	for _, env := range envs {
+		if !env.Changed {
+			continue
+		}
+		for _, row := range topologyChangeRows(env) {
+			data = append(data, append([]string{env.Name}, row...))
+		}
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Env", "App", "State", "Bump", "Now", "Was"})
+
+	table.AppendBulk(data)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.Render()
+}
+
+// topologyChangeRows returns app, state, bump, current and previous version of every changed app sorted by name
+func topologyChangeRows(env sdlcUtils.Environment) [][]string {
+	var rows [][]string
+
+	previousVersions := map[string]string{}
+	for _, app := range env.PreviousTopology {
+		previousVersions[app.Name] = app.Version
+	}
+	apps := append([]sdlc.AppVersion{}, env.Topology...)
+	sortByName(apps)
+	for _, app := range apps {
+		if app.State == sdlc.StateSame {
+			continue
+		}
+		now := app.Version
+		if app.State == sdlc.StateRemoved {
+			now = ""
+		}
+		rows = append(rows, []string{
+			app.Name,
+			string(app.State),
+			string(app.Bump),
+			now,
+			previousVersions[app.Name],
+		})
+	}
+	return rows
+}
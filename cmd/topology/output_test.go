@@ -0,0 +1,71 @@
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/stretchr/testify/assert"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+	"testing"
+)
+
+func testEnvironments() []sdlcUtils.Environment {
+	return []sdlcUtils.Environment{
+		{
+			Changed: true,
+			Topology: []sdlc.AppVersion{
+				{Name: "orders", Version: "1.1.0", State: sdlc.StateUpdated},
+				{Name: "billing", Version: "2.0.0"},
+			},
+			PreviousTopology: []sdlc.AppVersion{
+				{Name: "orders", Version: "1.0.0"},
+				{Name: "billing", Version: "2.0.0"},
+			},
+			Environment: jxV1.Environment{
+				ObjectMeta: metav1.ObjectMeta{Name: "staging"},
+				Spec:       jxV1.EnvironmentSpec{Namespace: "jx-staging", Order: 100},
+			},
+		},
+	}
+}
+
+func TestRenderTopologyJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := renderTopology(out, testEnvironments(), OutputJSON)
+	assert.NoError(t, err)
+
+	report := TopologyReport{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, TopologyReportAPIVersion, report.APIVersion)
+	assert.Equal(t, TopologyReportKind, report.Kind)
+	assert.Len(t, report.Environments, 1)
+
+	env := report.Environments[0]
+	assert.Equal(t, "staging", env.Name)
+	assert.Equal(t, "jx-staging", env.Namespace)
+	assert.Equal(t, int32(100), env.Order)
+	assert.True(t, env.Changed)
+	assert.Equal(t, "billing", env.Topology[0].Name)
+	assert.Equal(t, sdlc.StateUpdated, env.Topology[1].State)
+	assert.Equal(t, "1.0.0", env.PreviousTopology[1].Version)
+}
+
+func TestRenderTopologyYAML(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := renderTopology(out, nil, OutputYAML)
+	assert.NoError(t, err)
+
+	report := TopologyReport{}
+	assert.NoError(t, yaml.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, TopologyReportAPIVersion, report.APIVersion)
+	assert.NotNil(t, report.Environments)
+	assert.Empty(t, report.Environments)
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	assert.NoError(t, ValidateOutputFormat(OutputTable))
+	assert.Error(t, ValidateOutputFormat("xml"))
+}
@@ -56,7 +56,11 @@ func makeReleaseCmd(options *OptionsTopology) *cobra.Command {
 		Use:     "release",
 		Example: "create release of current topology",
 		Run: func(cmd *cobra.Command, args []string) {
-			opt.Run()
+			err := opt.Run()
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
 		},
 	}
 
@@ -83,8 +87,11 @@ func makeReleaseCmd(options *OptionsTopology) *cobra.Command {
 	return releaseCmd
 }
 
-func (opt *OptionsTopologyRelease) Run() {
-	envs := opt.GetEnvironments()
+func (opt *OptionsTopologyRelease) Run() error {
+	envs, err := opt.GetEnvironments()
+	if err != nil {
+		return err
+	}
 
 	var prevEnv *jxV1.Environment = nil
 	for _, env := range envs.Items {
@@ -123,6 +130,7 @@ func (opt *OptionsTopologyRelease) Run() {
 			envLog.Info("Skipping release creation")
 		}
 	}
+	return nil
 }
 
 func (opt *OptionsTopologyRelease) TopologyRelease(env *jxV1.Environment, version *semver.Version, prevVersion *semver.Version, prevEnvVersion *semver.Version, envLog *logrus.Entry) *sdlc.TopologyRelease {
@@ -145,6 +153,7 @@ func (opt *OptionsTopologyRelease) TopologyRelease(env *jxV1.Environment, versio
 			ObjectMeta: k8sV1.ObjectMeta{
 				Name:      version.String(),
 				Namespace: env.Spec.Namespace,
+				Labels:    map[string]string{sdlc.LabelTopologyHash: releaseTopologyHash(appVersions)},
 			},
 			Spec: sdlc.TopologyReleaseSpec{
 				Environment:    env.Name,
@@ -0,0 +1,224 @@
+package topology
+
+import (
+	"fmt"
+	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/pkg/gitclient"
+	"github.com/spf13/cobra"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
+	"io"
+	"io/ioutil"
+	k8sV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"time"
+)
+
+const (
+	// TopologySnapshotAPIVersion changes only with the snapshot format, saved snapshots stay readable
+	// when TopologyReport changes
+	TopologySnapshotAPIVersion = "sdlc.vitechteam.com/v1"
+	TopologySnapshotKind       = "TopologySnapshot"
+)
+
+// TopologySnapshot is a persisted topology of every environment, its schema is versioned by APIVersion
+type TopologySnapshot struct {
+	APIVersion   string                `json:"apiVersion"`
+	Kind         string                `json:"kind"`
+	CreatedAt    k8sV1.Time            `json:"createdAt"`
+	Source       SnapshotSource        `json:"source"`
+	Environments []EnvironmentSnapshot `json:"environments"`
+}
+
+type SnapshotSource struct {
+	GitURL              string `json:"gitUrl,omitempty"`
+	Revision            string `json:"revision,omitempty"`
+	HelmfileEnvironment string `json:"helmfileEnvironment,omitempty"`
+}
+
+type EnvironmentSnapshot struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	Order             int32             `json:"order"`
+	PromotionStrategy string            `json:"promotionStrategy,omitempty"`
+	Topology          []sdlc.AppVersion `json:"topology"`
+}
+
+type OptionsTopologySnapshot struct {
+	File string
+	*OptionsTopology
+}
+
+func makeSnapshotCmd(options *OptionsTopology) *cobra.Command {
+	opt := &OptionsTopologySnapshot{OptionsTopology: options}
+
+	snapshotCmd := &cobra.Command{
+		Use:     "snapshot",
+		Short:   "write topology of every environment into a file",
+		Example: "sdlc topology snapshot --out-file topology.json",
+		Run: func(cmd *cobra.Command, args []string) {
+			err := opt.Snapshot()
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	snapshotCmd.Flags().StringVarP(
+		&opt.File, "out-file", "", "-", "snapshot file, yaml if it has .yaml or .yml extension, json otherwise, - for stdout",
+	)
+
+	return snapshotCmd
+}
+
+func makeDiffSnapshotsCmd(options *OptionsTopology) *cobra.Command {
+	diffCmd := &cobra.Command{
+		Use:     "diff-snapshots <base snapshot> <head snapshot>",
+		Short:   "compare two topology snapshots without cluster access",
+		Example: "sdlc topology diff-snapshots release-1.json release-2.json",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := options.DiffSnapshots(os.Stdout, args[0], args[1])
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		},
+	}
+
+	diffCmd.Flags().StringVarP(
+		&options.Output, "output", "o", OutputTable, "output format: table, json or yaml",
+	)
+
+	return diffCmd
+}
+
+func (opt *OptionsTopologySnapshot) Snapshot() error {
+	environments, err := opt.GetEnvironmentsFromHelmFile(opt.Helmfile, opt.HelmfileDir)
+	if err != nil {
+		return err
+	}
+
+	revision, err := gitclient.GetLatestCommitSha(GitClient(), opt.HelmfileDir)
+	if err != nil {
+		log.WithField("dir", opt.HelmfileDir).WithError(err).Warn("can't determine git revision of helmfiles")
+		revision = ""
+	}
+
+	snapshot := NewTopologySnapshot(environments, SnapshotSource{
+		GitURL:              opt.GitUrl,
+		Revision:            revision,
+		HelmfileEnvironment: opt.HelmfileEnvironment,
+	})
+
+	format := OutputJSON
+	switch filepath.Ext(opt.File) {
+	case ".yaml", ".yml":
+		format = OutputYAML
+	}
+
+	if opt.File == "-" {
+		return WriteStructured(os.Stdout, snapshot, format)
+	}
+
+	file, err := os.Create(opt.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = WriteStructured(file, snapshot, format)
+	if err == nil {
+		log.WithField("file", opt.File).WithField("environments", len(snapshot.Environments)).Info("topology snapshot written")
+	}
+	return err
+}
+
+func NewTopologySnapshot(environments []sdlcUtils.Environment, source SnapshotSource) TopologySnapshot {
+	snapshot := TopologySnapshot{
+		APIVersion:   TopologySnapshotAPIVersion,
+		Kind:         TopologySnapshotKind,
+		CreatedAt:    k8sV1.NewTime(time.Now().UTC()),
+		Source:       source,
+		Environments: []EnvironmentSnapshot{},
+	}
+	for _, env := range environments {
+		topology := append([]sdlc.AppVersion{}, env.Topology...)
+		sortByName(topology)
+		snapshot.Environments = append(snapshot.Environments, EnvironmentSnapshot{
+			Name:              env.Name,
+			Namespace:         env.Spec.Namespace,
+			Order:             env.Spec.Order,
+			PromotionStrategy: string(env.Spec.PromotionStrategy),
+			Topology:          topology,
+		})
+	}
+	sort.SliceStable(snapshot.Environments, func(i, j int) bool {
+		left, right := snapshot.Environments[i], snapshot.Environments[j]
+		if left.Order != right.Order {
+			return left.Order < right.Order
+		}
+		return left.Namespace < right.Namespace
+	})
+	return snapshot
+}
+
+// LoadTopologySnapshot reads snapshot written by `topology snapshot` either in json or yaml
+func LoadTopologySnapshot(path string) (TopologySnapshot, error) {
+	snapshot := TopologySnapshot{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+
+	// yaml is a superset of json
+	err = yaml.Unmarshal(data, &snapshot)
+	if err != nil {
+		return snapshot, fmt.Errorf("can't read topology snapshot %s: %w", path, err)
+	}
+	if snapshot.Kind != TopologySnapshotKind || snapshot.APIVersion != TopologySnapshotAPIVersion {
+		return snapshot, fmt.Errorf("%s is not a topology snapshot, expected %s %s but found %s %s",
+			path, TopologySnapshotAPIVersion, TopologySnapshotKind, snapshot.APIVersion, snapshot.Kind)
+	}
+	return snapshot, nil
+}
+
+// ToEnvironments converts snapshot back to environments which can be compared
+func (snapshot TopologySnapshot) ToEnvironments() []sdlcUtils.Environment {
+	var environments []sdlcUtils.Environment
+	for _, env := range snapshot.Environments {
+		environments = append(environments, sdlcUtils.Environment{
+			Topology: env.Topology,
+			Environment: jxV1.Environment{
+				ObjectMeta: k8sV1.ObjectMeta{Name: env.Name},
+				Spec: jxV1.EnvironmentSpec{
+					Namespace:         env.Namespace,
+					Order:             env.Order,
+					PromotionStrategy: jxV1.PromotionStrategyType(env.PromotionStrategy),
+				},
+			},
+		})
+	}
+	return environments
+}
+
+func (opt *OptionsTopology) DiffSnapshots(out io.Writer, basePath string, headPath string) error {
+	if err := ValidateOutputFormat(opt.Output); err != nil {
+		return err
+	}
+
+	base, err := LoadTopologySnapshot(basePath)
+	if err != nil {
+		return err
+	}
+	head, err := LoadTopologySnapshot(headPath)
+	if err != nil {
+		return err
+	}
+
+	return renderTopology(out, compare(head.ToEnvironments(), base.ToEnvironments()), opt.Output)
+}
@@ -0,0 +1,67 @@
+package topology_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/topology"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSnapshot(t *testing.T, dir string, file string) {
+	opt := &topology.OptionsTopologySnapshot{
+		File: file,
+		OptionsTopology: &topology.OptionsTopology{
+			EnvironmentsFile: "testdata/diff/environments.yaml",
+			Options: &utils.Options{
+				Helmfile:    "helmfile.yaml",
+				HelmfileDir: dir,
+				GitUrl:      "https://github.com/vitech-team/test-env.git",
+			},
+		},
+	}
+	require.NoError(t, opt.Snapshot())
+}
+
+func TestSnapshotAndDiffSnapshots(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	baseFile := filepath.Join(tmpDir, "base.json")
+	headFile := filepath.Join(tmpDir, "head.yaml")
+	writeSnapshot(t, "testdata/diff/base", baseFile)
+	writeSnapshot(t, "testdata/diff/head", headFile)
+
+	base, err := topology.LoadTopologySnapshot(baseFile)
+	require.NoError(t, err)
+	assert.Equal(t, topology.TopologySnapshotAPIVersion, base.APIVersion)
+	assert.Equal(t, "https://github.com/vitech-team/test-env.git", base.Source.GitURL)
+	require.Len(t, base.Environments, 2)
+	assert.Equal(t, "staging", base.Environments[0].Name)
+	assert.Equal(t, int32(100), base.Environments[0].Order)
+	assert.Equal(t, "billing", base.Environments[0].Topology[0].Name)
+	assert.Equal(t, "production", base.Environments[1].Name)
+
+	opt := &topology.OptionsTopology{Output: topology.OutputJSON, Options: &utils.Options{}}
+	out := &bytes.Buffer{}
+	require.NoError(t, opt.DiffSnapshots(out, baseFile, headFile))
+
+	report := topology.TopologyReport{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	require.Len(t, report.Environments, 2)
+	assert.True(t, report.Environments[0].Changed)
+	assert.False(t, report.Environments[1].Changed)
+	assert.Contains(t, report.Environments[0].Topology, sdlc.AppVersion{Name: "search", Version: "0.1.0", State: sdlc.StateAdded})
+}
+
+func TestLoadTopologySnapshotRejectsOtherDocuments(t *testing.T) {
+	_, err := topology.LoadTopologySnapshot("testdata/diff/environments.yaml")
+	assert.Error(t, err)
+}
@@ -4,19 +4,15 @@ import (
 	"context"
 	"fmt"
 	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
-	"github.com/jenkins-x/jx-gitops/pkg/helmfiles"
 	"github.com/jenkins-x/jx-helpers/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/pkg/gitclient"
 	"github.com/jenkins-x/jx-helpers/pkg/gitclient/cli"
-	"github.com/jenkins-x/jx-helpers/pkg/yaml2s"
-	"github.com/olekukonko/tablewriter"
 	"github.com/roboll/helmfile/pkg/state"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	sdlcClient "github.com/vitech-team/sdlcctl/client/clientset/versioned/typed/largetest/v1beta1"
 	sdlcUtils "github.com/vitech-team/sdlcctl/cmd/utils"
-	"io/ioutil"
-	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"os"
@@ -26,18 +22,38 @@ import (
 )
 
 type OptionsTopology struct {
+	Output           string
+	EnvironmentsFile string
 	*sdlcUtils.Options
 }
 
 type OptionsTopologyTested struct {
-	Status string
-	Report string
-	Commit string
-	Repo   string
-	Image  string
+	Status     string
+	StartedAt  string
+	JUnit      []string
+	Env        string
+	Namespace  string
+	PipelineID string
+	Actor      string
+	Report     string
+	Commit     string
+	Repo       string
+	Image      string
+	Suite      string
+	// ArchiveDir is report directory which is archived to ArchiveTo, s3://<bucket>/<prefix> or configmap
+	ArchiveDir        string
+	ArchiveTo         string
+	S3Endpoint        string
+	S3Region          string
+	ConfigMapMaxBytes int
+	// ReportStore is created from ArchiveTo when not set
+	ReportStore sdlcUtils.ReportStore
 	*OptionsTopology
 }
 
+// ArchiveToConfigMap stores report archive in ConfigMap of the tested environment namespace
+const ArchiveToConfigMap = "configmap"
+
 var commandRunner cmdrunner.CommandRunner
 var gitClient gitclient.Interface
 
@@ -51,7 +67,7 @@ func init() {
 }
 
 func NewTopologyCmd(opts *sdlcUtils.Options) (*cobra.Command, *OptionsTopology) {
-	options := &OptionsTopology{opts}
+	options := &OptionsTopology{Options: opts}
 	optionTested := &OptionsTopologyTested{OptionsTopology: options}
 
 	command := &cobra.Command{
@@ -62,6 +78,18 @@ func NewTopologyCmd(opts *sdlcUtils.Options) (*cobra.Command, *OptionsTopology)
 		},
 	}
 
+	command.PersistentFlags().StringVarP(
+		&options.EnvironmentsFile,
+		"environments",
+		"",
+		"",
+		"yaml `file` with Jenkins X EnvironmentList (e.g. output of 'kubectl get environments -o yaml') used instead of cluster environments",
+	)
+
+	if err := command.MarkPersistentFlagFilename("environments", "yaml", "yml"); err != nil {
+		panic(err.Error())
+	}
+
 	printCmd := &cobra.Command{
 		Use:     "print",
 		Example: "print current topology VS previous",
@@ -74,6 +102,10 @@ func NewTopologyCmd(opts *sdlcUtils.Options) (*cobra.Command, *OptionsTopology)
 		},
 	}
 
+	printCmd.Flags().StringVarP(
+		&options.Output, "output", "o", OutputTable, "output format: table, json or yaml",
+	)
+
 	testedCmd := &cobra.Command{
 		Use:     "tested",
 		Example: "create large test execution for current topology ",
@@ -87,7 +119,28 @@ func NewTopologyCmd(opts *sdlcUtils.Options) (*cobra.Command, *OptionsTopology)
 	}
 
 	testedCmd.Flags().StringVarP(
-		&optionTested.Status, "status", "", "", "large test status success/failed",
+		&optionTested.Status, "status", "", "", "large test result: Succeeded, Failed, Error, Running or Pending to let controller run --image (ok/success/failed are accepted too)",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.PipelineID, "pipeline-id", "", sdlcUtils.EnvOrDefault("BUILD_ID", ""), "id of CI run which executed tests, $BUILD_ID by default",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.Actor, "actor", "", "", "user who triggered CI run",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.Suite, "suite", "", "", "name of executed test suite, e.g. smoke or e2e",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.Env, "env", "", "", "name of the tested environment",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.Namespace, "namespace", "", "", "namespace of the tested environment",
+	)
+	testedCmd.Flags().StringSliceVarP(
+		&optionTested.JUnit, "junit", "", nil, "glob of JUnit XML reports, result is derived from them instead of --status",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.StartedAt, "started-at", "", "", "RFC3339 time when large tests were started, now if empty",
 	)
 	testedCmd.Flags().StringVarP(
 		&optionTested.Report, "report", "", "", "report url or place where it can be found",
@@ -102,7 +155,22 @@ func NewTopologyCmd(opts *sdlcUtils.Options) (*cobra.Command, *OptionsTopology)
 		&optionTested.Image, "image", "", "", "large reports produced",
 	)
 
-	testedCmd.MarkFlagRequired("status")
+	testedCmd.Flags().StringVarP(
+		&optionTested.ArchiveDir, "archive-dir", "", "", "report directory which is archived to --archive-to",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.ArchiveTo, "archive-to", "", "", "where report archive is stored: s3://<bucket>/<prefix> or configmap",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.S3Endpoint, "s3-endpoint", "", "", "endpoint of S3-compatible storage, e.g. MinIO, AWS S3 if empty",
+	)
+	testedCmd.Flags().StringVarP(
+		&optionTested.S3Region, "s3-region", "", sdlcUtils.EnvOrDefault("AWS_REGION", "us-east-1"), "region of s3 bucket, $AWS_REGION by default",
+	)
+	testedCmd.Flags().IntVarP(
+		&optionTested.ConfigMapMaxBytes, "configmap-max-bytes", "", sdlcUtils.DefaultConfigMapMaxBytes, "size limit of report archive stored in ConfigMap",
+	)
+
 	testedCmd.MarkFlagRequired("report")
 	testedCmd.MarkFlagRequired("commit")
 	testedCmd.MarkFlagRequired("repo")
@@ -111,175 +179,404 @@ func NewTopologyCmd(opts *sdlcUtils.Options) (*cobra.Command, *OptionsTopology)
 	command.AddCommand(printCmd)
 	command.AddCommand(testedCmd)
 	command.AddCommand(makeReleaseCmd(options))
+	command.AddCommand(makeDiffCmd(options))
+	command.AddCommand(makeDriftCmd(options))
+	command.AddCommand(makeSnapshotCmd(options))
+	command.AddCommand(makeDiffSnapshotsCmd(options))
+	command.AddCommand(makeCommentCmd(options))
 
 	return command, options
 }
 
 func (opt *OptionsTopologyTested) MarkWithLargeTestExec() error {
-	currentHelmState := opt.GetEnvironmentsFromHelmFile(opt.Helmfile, opt.HelmfileDir)
-	for _, env := range currentHelmState {
-		opt.KubeClient, opt.JxClient, opt.LtClient = sdlcUtils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
-		err := createIfNotExists(opt, env)
+	if opt.Env == "" && opt.Namespace == "" {
+		return fmt.Errorf("either --env or --namespace of the tested environment is required")
+	}
+	if !sdlc.ValidReport(opt.Report) {
+		return fmt.Errorf("--report must be absolute url, got %q", opt.Report)
+	}
+	if (opt.ArchiveDir == "") != (opt.ArchiveTo == "") {
+		return fmt.Errorf("both --archive-dir and --archive-to are required to archive report")
+	}
+	result, tests, err := opt.testResult()
+	if err != nil {
+		return err
+	}
+	now := metav1.Now()
+	startTime := now
+	if opt.StartedAt != "" {
+		started, err := time.Parse(time.RFC3339, opt.StartedAt)
 		if err != nil {
-			return err
+			return fmt.Errorf("can't parse --started-at: %w", err)
 		}
+		startTime = metav1.NewTime(started)
+	}
+
+	currentHelmState, err := opt.GetEnvironmentsFromHelmFile(opt.Helmfile, opt.HelmfileDir)
+	if err != nil {
+		return err
+	}
+	testedEnvs, err := opt.selectTestedEnvironments(currentHelmState)
+	if err != nil {
+		return err
+	}
+	archive, err := opt.archiveReport()
+	if err != nil {
+		return err
+	}
+	for _, env := range testedEnvs {
+		opt.KubeClient, opt.JxClient, opt.LtClient = sdlcUtils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
 
 		lte := &sdlc.LargeTestExecution{
 			ObjectMeta: metav1.ObjectMeta{
 				Namespace:    env.Spec.Namespace,
 				GenerateName: fmt.Sprintf("%s-%s", env.Spec.Namespace, opt.Commit),
+				Labels:       opt.labels(env.Topology),
 			},
 			Spec: sdlc.LargeTestExecutionSpec{
 				Image:       opt.Image,
-				Result:      opt.Status,
 				Environment: env.Name,
 				Namespace:   env.Spec.Namespace,
 				Report:      opt.Report,
-				Time:        time.Now().String(),
 				Topology:    env.Topology,
+				Commit:      opt.Commit,
+				Repository:  sdlcUtils.StripCredentials(opt.Repo),
+				PipelineID:  opt.PipelineID,
+				Actor:       opt.Actor,
+				Suite:       opt.Suite,
 			},
 		}
-		created, err := opt.LtClient.LargetestV1beta1().LargeTestExecutions(env.Spec.Namespace).Create(
-			context.TODO(), lte, metav1.CreateOptions{},
-		)
+		client := opt.LtClient.LargetestV1beta1().LargeTestExecutions(env.Spec.Namespace)
+		created, err := client.Create(context.TODO(), lte, metav1.CreateOptions{})
 		if err != nil {
-			return err
+			return fmt.Errorf("can't create LargeTestExecution in namespace %s: %w", env.Spec.Namespace, err)
+		}
+		// archive is stored once execution exists, so it always has an owner
+		if archive != nil {
+			created, err = opt.attachArchive(client, created, archive)
+			if err != nil {
+				return discardExecution(client, created, err)
+			}
 		}
-		log.WithField("name", created.Name).
+
+		// status is ignored on create once status subresource is enabled
+		if result != sdlc.ResultPending {
+			created.Status.StartTime = startTime.DeepCopy()
+		}
+		created.Status.Tests = tests.DeepCopy()
+		created.SetResult(result, now)
+		updated, err := client.UpdateStatus(context.TODO(), created, metav1.UpdateOptions{})
+		if err != nil {
+			return discardExecution(client, created,
+				fmt.Errorf("can't update status of LargeTestExecution %s/%s: %w", env.Spec.Namespace, created.Name, err))
+		}
+		log.WithField("name", updated.Name).
 			WithField("ns", env.Spec.Namespace).
+			WithField("result", updated.Status.Result).
 			Info("new LargeTestExecution created")
 	}
 
 	return nil
 }
 
-func createIfNotExists(opt *OptionsTopologyTested, env sdlcUtils.Environment) error {
-	var _, err = opt.KubeClient.CoreV1().Namespaces().Get(context.TODO(), env.Spec.Namespace, metav1.GetOptions{})
-	if err != nil {
-		if err.(*errors.StatusError).ErrStatus.Reason == metav1.StatusReasonNotFound {
-			_, err = opt.KubeClient.CoreV1().Namespaces().Create(
-				context.TODO(),
-				&v1.Namespace{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: env.Spec.Namespace,
-					},
-				}, metav1.CreateOptions{},
-			)
+// archiveReport packs --archive-dir once for every tested environment, it is nil when archiving is not requested
+func (opt *OptionsTopologyTested) archiveReport() ([]byte, error) {
+	if opt.ArchiveDir == "" {
+		return nil, nil
+	}
+
+	if opt.ReportStore == nil {
+		switch {
+		case opt.ArchiveTo == ArchiveToConfigMap:
+			opt.KubeClient, opt.JxClient, opt.LtClient = sdlcUtils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
+			opt.ReportStore = &sdlcUtils.ConfigMapStore{KubeClient: opt.KubeClient, MaxBytes: opt.ConfigMapMaxBytes}
+		case strings.HasPrefix(opt.ArchiveTo, "s3://"):
+			store, err := sdlcUtils.NewS3Store(opt.ArchiveTo, opt.S3Endpoint, opt.S3Region)
 			if err != nil {
-				return err
+				return nil, err
 			}
-		} else {
-			return err
+			opt.ReportStore = store
+		default:
+			return nil, fmt.Errorf("unsupported --archive-to %q, expected s3://<bucket>/<prefix> or %s", opt.ArchiveTo, ArchiveToConfigMap)
 		}
 	}
-	return err
-}
 
-func (opt *OptionsTopology) Print() error {
+	return sdlcUtils.ArchiveDir(opt.ArchiveDir)
+}
 
-	comparedEnvironments, err := opt.GetComparedTopology()
+// attachArchive stores archive shared by executions with the same report and references it from created execution,
+// which becomes one of the archive owners
+func (opt *OptionsTopologyTested) attachArchive(
+	client sdlcClient.LargeTestExecutionInterface, created *sdlc.LargeTestExecution, archive []byte,
+) (*sdlc.LargeTestExecution, error) {
+	checksum := sdlcUtils.ArchiveChecksum(archive)
+	location, err := opt.ReportStore.Store(context.TODO(), created.Namespace, checksum, archive)
+	if err != nil {
+		return created, err
+	}
+	log.WithField("location", location).WithField("checksum", checksum).Info("report has been archived")
 
+	created.Spec.Archive = &sdlc.ReportArchive{Location: location, Checksum: checksum, Size: int64(len(archive))}
+	updated, err := client.Update(context.TODO(), created, metav1.UpdateOptions{})
 	if err != nil {
-		panic(err.Error())
+		return created, fmt.Errorf("can't reference report archive from LargeTestExecution %s/%s: %w", created.Namespace, created.Name, err)
 	}
-	var data = [][]string{}
 
-	for _, env := range comparedEnvironments {
-		envName := env.Name
+	owner := *metav1.NewControllerRef(updated, sdlc.SchemeGroupVersion.WithKind("LargeTestExecution"))
+	owner.Controller = nil
+	return updated, opt.ReportStore.Adopt(context.TODO(), location, owner)
+}
 
-		if env.Changed {
-			sortByName(env.Topology)
-			sortByName(env.PreviousTopology)
-			cols := []string{
-				envName,
-				fmt.Sprintf("%t", env.Changed),
-				strings.Join(getNames(env.Topology), ", "),
-				strings.Join(getNames(env.PreviousTopology), ", "),
-			}
-			data = append(data, cols)
+// discardExecution deletes execution which failed to be completed, it would look pending forever otherwise
+func discardExecution(client sdlcClient.LargeTestExecutionInterface, lte *sdlc.LargeTestExecution, cause error) error {
+	if err := client.Delete(context.TODO(), lte.Name, metav1.DeleteOptions{}); err != nil {
+		log.WithField("name", lte.Name).WithField("ns", lte.Namespace).
+			Warnf("can't delete incomplete LargeTestExecution: %s", err)
+	}
+	return cause
+}
+
+// labels makes traceability metadata and tested topology queryable by label selector, empty values are omitted
+func (opt *OptionsTopologyTested) labels(topology []sdlc.AppVersion) map[string]string {
+	labels := map[string]string{sdlc.LabelTopologyHash: sdlcUtils.TopologyHash(topology)}
+	for key, value := range map[string]string{
+		sdlc.LabelCommit:     sdlcUtils.LabelValue(opt.Commit),
+		sdlc.LabelRepository: sdlcUtils.RepositoryLabelValue(opt.Repo),
+		sdlc.LabelPipelineID: sdlcUtils.LabelValue(opt.PipelineID),
+		sdlc.LabelActor:      sdlcUtils.LabelValue(opt.Actor),
+	} {
+		if value != "" {
+			labels[key] = value
 		}
 	}
+	return labels
+}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Env", "Env Changed", "Now", "Was"})
+// testResult derives result from --junit reports when they are given, --status is used otherwise
+func (opt *OptionsTopologyTested) testResult() (sdlc.Result, *sdlc.TestSummary, error) {
+	if len(opt.JUnit) == 0 {
+		if opt.Status == "" {
+			return "", nil, fmt.Errorf("either --status or --junit is required")
+		}
+		result, err := sdlc.ParseResult(opt.Status)
+		return result, nil, err
+	}
 
-	table.AppendBulk(data)
-	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	table.SetCenterSeparator("|")
-	table.Render()
+	tests, err := sdlcUtils.ParseJUnitReports(opt.JUnit)
+	if err != nil {
+		return "", nil, err
+	}
+	result := sdlcUtils.ResultOfTests(tests)
+	if opt.Status != "" {
+		status, err := sdlc.ParseResult(opt.Status)
+		if err == nil && status != result {
+			log.WithField("status", status).WithField("junit", result).Warn("--status is ignored, result is derived from junit reports")
+		}
+	}
 
-	return err
+	log.WithField("result", result).
+		WithField("total", tests.Total).
+		WithField("failed", tests.Failed).
+		WithField("errors", tests.Errors).
+		WithField("skipped", tests.Skipped).
+		Info("junit reports parsed")
+	return result, tests, nil
 }
 
-func getNames(apps []sdlc.AppVersion) []string {
-	var names []string
-	for _, app := range apps {
-		names = append(names, fmt.Sprintf("%s:%s", app.Name, app.Version))
+// selectTestedEnvironments keeps environments matching both --env and --namespace when they are set
+func (opt *OptionsTopologyTested) selectTestedEnvironments(envs []sdlcUtils.Environment) ([]sdlcUtils.Environment, error) {
+	var selected []sdlcUtils.Environment
+	var available []string
+	for _, env := range envs {
+		available = append(available, fmt.Sprintf("%s (%s)", env.Name, env.Spec.Namespace))
+		if opt.Env != "" && env.Name != opt.Env {
+			continue
+		}
+		if opt.Namespace != "" && env.Spec.Namespace != opt.Namespace {
+			continue
+		}
+		selected = append(selected, env)
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no environment matches --env %q --namespace %q, available: %s",
+			opt.Env, opt.Namespace, strings.Join(available, ", "))
 	}
-	return names
+	return selected, nil
+}
+
+func (opt *OptionsTopology) Print() error {
+	if err := ValidateOutputFormat(opt.Output); err != nil {
+		return err
+	}
+
+	comparedEnvironments, err := opt.GetComparedTopology()
+	if err != nil {
+		return err
+	}
+
+	return renderTopology(os.Stdout, comparedEnvironments, opt.Output)
 }
 
 func sortByName(apps []sdlc.AppVersion) {
-	sort.Slice(apps, func(i, j int) bool {
-		switch strings.Compare(apps[i].Name, apps[i].Name) {
-		case -1:
-			return true
-		case 1:
-			return false
-		}
-		return apps[i].Name > apps[j].Name
+	sort.SliceStable(apps, func(i, j int) bool {
+		return apps[i].Name < apps[j].Name
 	})
 }
 
 func (opt *OptionsTopology) GetComparedTopology() ([]sdlcUtils.Environment, error) {
-	masterRepoTmpDir, err := ioutil.TempDir("", "")
+	if opt.GitUrl == "" {
+		return nil, fmt.Errorf("--gitUrl is required to clone base helmfiles")
+	}
+	if opt.HeadDir != "" && opt.HeadRef != "" {
+		return nil, fmt.Errorf("--head-dir and --head-ref can't be used together")
+	}
+
+	log.WithField("git", opt.GitUrl).WithField("ref", opt.BaseRef).Debug("cloning base")
+	baseDir, err := checkoutRef(GitClient(), opt.GitUrl, opt.BaseRef)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(baseDir)
 
-	cloneDir, err := gitclient.CloneToDir(GitClient(), opt.GitUrl, masterRepoTmpDir)
+	headDir := opt.HeadDir
+	if headDir == "" {
+		headDir = opt.HelmfileDir
+	}
+	if opt.HeadRef != "" {
+		log.WithField("git", opt.GitUrl).WithField("ref", opt.HeadRef).Debug("cloning head")
+		headDir, err = checkoutRef(GitClient(), opt.GitUrl, opt.HeadRef)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(headDir)
+	}
 
-	log.WithField("git", opt.GitUrl).WithField("folder", masterRepoTmpDir).Debug("cloning base")
 	log.Debug("checking topology...")
 
-	currentHelmState := opt.GetEnvironmentsFromHelmFile(opt.Helmfile, opt.HelmfileDir)
-	masterHelmState := opt.GetEnvironmentsFromHelmFile(opt.Helmfile, cloneDir)
+	currentHelmState, err := opt.GetEnvironmentsFromHelmFile(opt.Helmfile, headDir)
+	if err != nil {
+		return nil, err
+	}
+	baseHelmState, err := opt.GetEnvironmentsFromHelmFile(opt.Helmfile, baseDir)
+	if err != nil {
+		return nil, err
+	}
 
-	comparedEnvironments := compare(currentHelmState, masterHelmState)
-	return comparedEnvironments, err
+	return compare(currentHelmState, baseHelmState), nil
 }
 
-func compare(currentState []sdlcUtils.Environment, newState []sdlcUtils.Environment) []sdlcUtils.Environment {
+func compare(currentState []sdlcUtils.Environment, baseState []sdlcUtils.Environment) []sdlcUtils.Environment {
 	var results []sdlcUtils.Environment
+	comparedNamespaces := map[string]bool{}
 	for _, currentEnvState := range currentState {
-		var appResults []sdlc.AppVersion
-		for _, newEnvState := range newState {
-			if currentEnvState.Spec.Namespace == newEnvState.Spec.Namespace {
-				if len(currentEnvState.Topology) != len(newEnvState.Topology) {
-					currentEnvState.Changed = true
-				}
-				for _, appVersion := range currentEnvState.Topology {
-					if !sdlcUtils.ContainsVersion(appVersion, newEnvState.Topology) {
-						appVersion.State = sdlc.StateUpdated
-						currentEnvState.Changed = true
-					}
-					appResults = append(appResults, appVersion)
-				}
-				currentEnvState.PreviousTopology = newEnvState.Topology
+		var baseTopology []sdlc.AppVersion
+		for _, baseEnvState := range baseState {
+			if currentEnvState.Spec.Namespace == baseEnvState.Spec.Namespace {
+				baseTopology = append(baseTopology, baseEnvState.Topology...)
 			}
 		}
-		currentEnvState.Topology = appResults
+		comparedNamespaces[currentEnvState.Spec.Namespace] = true
+		currentEnvState.PreviousTopology = baseTopology
+		currentEnvState.Topology = compareTopology(currentEnvState.Topology, baseTopology)
+		currentEnvState.Changed = isChanged(currentEnvState.Topology)
 		results = append(results, currentEnvState)
 	}
+
+	// environments which are not described by current helmfiles anymore
+	for _, baseEnvState := range baseState {
+		if comparedNamespaces[baseEnvState.Spec.Namespace] {
+			continue
+		}
+		comparedNamespaces[baseEnvState.Spec.Namespace] = true
+		baseEnvState.PreviousTopology = baseEnvState.Topology
+		baseEnvState.Topology = compareTopology(nil, baseEnvState.Topology)
+		baseEnvState.Changed = isChanged(baseEnvState.Topology)
+		results = append(results, baseEnvState)
+	}
+	return results
+}
+
+// compareTopology returns current topology where every app is marked with its State
+// against previous topology, apps missing in current topology are appended as removed
+func compareTopology(current []sdlc.AppVersion, previous []sdlc.AppVersion) []sdlc.AppVersion {
+	previousByName := map[string]sdlc.AppVersion{}
+	for _, app := range previous {
+		previousByName[app.Name] = app
+	}
+
+	var results []sdlc.AppVersion
+	currentNames := map[string]bool{}
+	for _, app := range current {
+		currentNames[app.Name] = true
+		previousApp, found := previousByName[app.Name]
+		switch {
+		case !found:
+			app.State = sdlc.StateAdded
+		case previousApp.Version != app.Version:
+			app.State = sdlc.StateUpdated
+			app.Bump = sdlcUtils.ClassifyBump(previousApp.Version, app.Version)
+		default:
+			app.State = sdlc.StateSame
+		}
+		results = append(results, app)
+	}
+
+	for _, app := range previous {
+		if !currentNames[app.Name] {
+			currentNames[app.Name] = true
+			app.State = sdlc.StateRemoved
+			results = append(results, app)
+		}
+	}
 	return results
 }
 
-func (opt *OptionsTopology) GetEnvironmentsFromHelmFile(helmFile string, dir string) []sdlcUtils.Environment {
-	gatherHelmfiles := HelmFilePath(helmFile, dir)
-	environments := opt.GetEnvironments().Items
+func isChanged(topology []sdlc.AppVersion) bool {
+	for _, app := range topology {
+		if app.State != sdlc.StateSame {
+			return true
+		}
+	}
+	return false
+}
+
+func (opt *OptionsTopology) GetEnvironmentsFromHelmFile(helmFile string, dir string) ([]sdlcUtils.Environment, error) {
+	var environments []jxV1.Environment
+	if opt.EnvironmentsFile != "" {
+		var err error
+		environments, err = LoadEnvironmentsFile(opt.EnvironmentsFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		envs, err := opt.GetEnvironments()
+		if err != nil {
+			return nil, err
+		}
+		environments = envs.Items
+	}
+	return GatherTopology(helmFile, dir, opt.HelmfileEnvironment, environments)
+}
+
+// GatherTopology reads topology of every environment described by helmfiles in dir rendered for helmfileEnvironment,
+// environments are matched with helmfiles by namespace
+func GatherTopology(helmFile string, dir string, helmfileEnvironment string, environments []jxV1.Environment) ([]sdlcUtils.Environment, error) {
+	helmStates, err := LoadHelmStates(helmFile, dir, helmfileEnvironment)
+	if err != nil {
+		return nil, err
+	}
 
 	var changedEnvironments []sdlcUtils.Environment
-	for _, helmFile := range gatherHelmfiles {
+	for _, helmState := range helmStates {
 		var environmentVersions []sdlc.AppVersion
-		helmState := readHelmState(helmFile)
-		releases := helmState.Releases
+		var releases []state.ReleaseSpec
+		for _, release := range helmState.Releases {
+			if release.Installed != nil && !*release.Installed {
+				continue
+			}
+			releases = append(releases, release)
+		}
 		if releases != nil {
 			for _, release := range releases {
 				environmentVersions = append(
@@ -303,7 +600,7 @@ func (opt *OptionsTopology) GetEnvironmentsFromHelmFile(helmFile string, dir str
 		}
 	}
 
-	return changedEnvironments
+	return changedEnvironments, nil
 }
 
 func findNamespace(releases []state.ReleaseSpec) string {
@@ -320,48 +617,43 @@ func findEnvironment(envs []jxV1.Environment, namesapce string) jxV1.Environment
 			return env
 		}
 	}
-	return jxV1.Environment{Spec: jxV1.EnvironmentSpec{
-		Namespace: namesapce,
-	}}
+	return jxV1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namesapce,
+		},
+		Spec: jxV1.EnvironmentSpec{
+			Namespace: namesapce,
+		},
+	}
 }
 
-func (opt *OptionsTopology) GetEnvironments() *jxV1.EnvironmentList {
+// GetEnvironments lists Jenkins X environments of --env-namespace sorted by order
+func (opt *OptionsTopology) GetEnvironments() (*jxV1.EnvironmentList, error) {
 	opt.KubeClient, opt.JxClient, opt.LtClient = sdlcUtils.NewLazyClients(opt.KubeClient, opt.JxClient, opt.LtClient)
 
-	envs, err := opt.JxClient.JenkinsV1().Environments("jx").List(context.TODO(), metav1.ListOptions{})
+	namespace := opt.EnvNamespace
+	if namespace == "" {
+		namespace = sdlcUtils.DefaultEnvNamespace
+	}
+
+	envs, err := opt.JxClient.JenkinsV1().Environments(namespace).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
-		panic("can't fetch environment list")
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf(
+				"can't fetch environment list: Environment CRD (environments.jenkins.io) is not installed in the cluster, "+
+					"install Jenkins X or pass environments with --environments file: %w", err,
+			)
+		}
+		return nil, fmt.Errorf("can't fetch environment list in namespace %s: %w", namespace, err)
+	}
+	if len(envs.Items) == 0 {
+		log.WithField("namespace", namespace).Warn("no environments found, check --env-namespace")
 	}
 	sort.Slice(envs.Items, func(i, j int) bool {
 		return envs.Items[i].Spec.Order < envs.Items[j].Spec.Order
 	})
 
-	return envs
-}
-
-func readHelmState(helmFilePath string) state.HelmState {
-	helmState := state.HelmState{}
-	err := yaml2s.LoadFile(helmFilePath, &helmState)
-	if err != nil {
-		panic("HELMFILE")
-	}
-	return helmState
-}
-
-func HelmFilePath(helmfile string, dir string) []string {
-	gatherHelmfiles, err := helmfiles.GatherHelmfiles(helmfile, dir)
-	var helmFiles = map[string]string{}
-	for _, helmfile := range gatherHelmfiles {
-		helmFiles[helmfile.Filepath] = helmfile.RelativePathToRoot
-	}
-	keys := make([]string, 0, len(helmFiles))
-	for k := range helmFiles {
-		keys = append(keys, k)
-	}
-	if err != nil {
-		panic("cant extract sub helmfiles")
-	}
-	return keys
+	return envs, nil
 }
 
 func GitClient() gitclient.Interface {
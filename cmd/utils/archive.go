@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// ReportArchiveKey is the ConfigMap binary data key of report archive
+	ReportArchiveKey = "report.tar.gz"
+	// AnnotationArchiveChecksum lets reuse ConfigMap created for the same report
+	AnnotationArchiveChecksum = "largetest.vitechteam.com/checksum"
+	// DefaultConfigMapMaxBytes leaves room for base64 encoding of binary data within 1MiB object size limit
+	DefaultConfigMapMaxBytes = 700 * 1024
+)
+
+// ReportStore keeps report archives and returns their durable location, archive is shared by executions
+// with the same report, so it carries no execution metadata
+type ReportStore interface {
+	Store(ctx context.Context, namespace string, checksum string, data []byte) (string, error)
+	// Adopt makes owner responsible for lifecycle of stored archive, if store supports it
+	Adopt(ctx context.Context, location string, owner metav1.OwnerReference) error
+}
+
+// ArchiveDir packs regular files of dir into tar.gz, entries are sorted and have no timestamps,
+// so the same content always has the same checksum
+func ArchiveDir(dir string) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	gz := gzip.NewWriter(buffer)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:    filepath.ToSlash(name),
+			Mode:    0644,
+			Size:    info.Size(),
+			ModTime: time.Unix(0, 0),
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		content, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		_, err = io.Copy(tw, content)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't archive report directory %s: %w", dir, err)
+	}
+
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	if err = gz.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// ArchiveChecksum is sha256:<hex> of data
+func ArchiveChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// S3Store uploads archives to S3-compatible bucket, they are named by checksum
+type S3Store struct {
+	Client s3iface.S3API
+	Bucket string
+	Prefix string
+}
+
+// NewS3Store creates store for s3://<bucket>/<prefix> target, endpoint of S3-compatible storage (e.g. MinIO)
+// is addressed in path style, credentials are taken from AWS environment or shared config
+func NewS3Store(target string, endpoint string, region string) (*S3Store, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Scheme != "s3" || parsed.Host == "" {
+		return nil, fmt.Errorf("s3 target must be s3://<bucket>/<prefix>, got %q", target)
+	}
+
+	config := aws.NewConfig().WithRegion(region)
+	if endpoint != "" {
+		config = config.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	awsSession, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("can't create s3 session: %w", err)
+	}
+
+	return &S3Store{
+		Client: s3.New(awsSession),
+		Bucket: parsed.Host,
+		Prefix: strings.Trim(parsed.Path, "/"),
+	}, nil
+}
+
+func (store *S3Store) Store(ctx context.Context, namespace string, checksum string, data []byte) (string, error) {
+	digest := strings.TrimPrefix(checksum, "sha256:")
+	key := path.Join(store.Prefix, digest+".tar.gz")
+	_, err := store.Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(store.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/gzip"),
+		Metadata:    map[string]*string{"Sha256": aws.String(digest)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("can't upload report archive to s3://%s/%s: %w", store.Bucket, key, err)
+	}
+	return fmt.Sprintf("s3://%s/%s", store.Bucket, key), nil
+}
+
+// Adopt is not supported, lifecycle of bucket objects is managed by bucket policies
+func (store *S3Store) Adopt(ctx context.Context, location string, owner metav1.OwnerReference) error {
+	return nil
+}
+
+// ConfigMapStore keeps small archives in ConfigMap of execution namespace
+type ConfigMapStore struct {
+	KubeClient kubernetes.Interface
+	MaxBytes   int
+}
+
+func (store *ConfigMapStore) Store(ctx context.Context, namespace string, checksum string, data []byte) (string, error) {
+	if len(data) > store.MaxBytes {
+		return "", fmt.Errorf("report archive is %d bytes, more than ConfigMap limit of %d bytes, archive it to s3 instead",
+			len(data), store.MaxBytes)
+	}
+
+	name := "largetest-report-" + strings.TrimPrefix(checksum, "sha256:")[:20]
+	location := fmt.Sprintf("configmap://%s/%s", namespace, name)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{AnnotationArchiveChecksum: checksum},
+		},
+		BinaryData: map[string][]byte{ReportArchiveKey: data},
+	}
+
+	client := store.KubeClient.CoreV1().ConfigMaps(namespace)
+	_, err := client.Create(ctx, configMap, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		existing, err := client.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("can't get report ConfigMap %s/%s: %w", namespace, name, err)
+		}
+		if existing.Annotations[AnnotationArchiveChecksum] != checksum {
+			return "", fmt.Errorf("report ConfigMap %s/%s exists with another checksum", namespace, name)
+		}
+		return location, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("can't create report ConfigMap %s/%s: %w", namespace, name, err)
+	}
+	return location, nil
+}
+
+// Adopt adds owner reference, so ConfigMap is garbage collected when all executions using it are deleted
+func (store *ConfigMapStore) Adopt(ctx context.Context, location string, owner metav1.OwnerReference) error {
+	parts := strings.SplitN(strings.TrimPrefix(location, "configmap://"), "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid ConfigMap location %q", location)
+	}
+	client := store.KubeClient.CoreV1().ConfigMaps(parts[0])
+
+	configMap, err := client.Get(ctx, parts[1], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("can't get report ConfigMap %s: %w", location, err)
+	}
+	for _, reference := range configMap.OwnerReferences {
+		if reference.UID == owner.UID {
+			return nil
+		}
+	}
+	configMap.OwnerReferences = append(configMap.OwnerReferences, owner)
+	_, err = client.Update(ctx, configMap, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("can't set owner of report ConfigMap %s: %w", location, err)
+	}
+	return nil
+}
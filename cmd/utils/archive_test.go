@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeFake "k8s.io/client-go/kubernetes/fake"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestArchiveDirIsReproducible(t *testing.T) {
+	first, err := ArchiveDir("testdata/junit")
+	require.NoError(t, err)
+	second, err := ArchiveDir("testdata/junit")
+	require.NoError(t, err)
+
+	assert.Equal(t, ArchiveChecksum(first), ArchiveChecksum(second))
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", ArchiveChecksum(first))
+
+	gz, err := gzip.NewReader(bytes.NewReader(first))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	header, err := tr.Next()
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(tr)
+	require.NoError(t, err)
+	expected, err := ioutil.ReadFile("testdata/junit/" + header.Name)
+	require.NoError(t, err)
+	assert.Equal(t, expected, content)
+}
+
+func TestArchiveMissingDir(t *testing.T) {
+	_, err := ArchiveDir("testdata/missing")
+
+	assert.Error(t, err)
+}
+
+func TestS3StoreUploadsByChecksum(t *testing.T) {
+	var uploadedPath string
+	var uploaded []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		uploadedPath = r.URL.Path
+		uploaded, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+	for name, value := range map[string]string{"AWS_ACCESS_KEY_ID": "minio", "AWS_SECRET_ACCESS_KEY": "minio123"} {
+		previous, ok := os.LookupEnv(name)
+		require.NoError(t, os.Setenv(name, value))
+		defer func(name string) {
+			if ok {
+				_ = os.Setenv(name, previous)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		}(name)
+	}
+
+	store, err := NewS3Store("s3://reports/large-tests/", server.URL, "us-east-1")
+	require.NoError(t, err)
+	data := []byte("report")
+	checksum := ArchiveChecksum(data)
+	location, err := store.Store(context.TODO(), "jx-staging", checksum, data)
+
+	require.NoError(t, err)
+	digest := checksum[len("sha256:"):]
+	assert.Equal(t, "s3://reports/large-tests/"+digest+".tar.gz", location)
+	assert.Equal(t, "/reports/large-tests/"+digest+".tar.gz", uploadedPath)
+	assert.Equal(t, data, uploaded)
+}
+
+func TestNewS3StoreRejectsInvalidTarget(t *testing.T) {
+	_, err := NewS3Store("reports/large-tests", "", "us-east-1")
+
+	assert.Error(t, err)
+}
+
+func TestConfigMapStore(t *testing.T) {
+	kubeClient := kubeFake.NewSimpleClientset()
+	store := &ConfigMapStore{KubeClient: kubeClient, MaxBytes: 16}
+	data := []byte("report")
+	checksum := ArchiveChecksum(data)
+
+	location, err := store.Store(context.TODO(), "jx-staging", checksum, data)
+	require.NoError(t, err)
+	again, err := store.Store(context.TODO(), "jx-staging", checksum, data)
+	require.NoError(t, err)
+	assert.Equal(t, location, again)
+
+	owner := metav1.OwnerReference{APIVersion: "largetest.vitechteam.com/v1beta1", Kind: "LargeTestExecution", Name: "e1", UID: types.UID("1")}
+	require.NoError(t, store.Adopt(context.TODO(), location, owner))
+	require.NoError(t, store.Adopt(context.TODO(), location, owner))
+
+	configMaps, err := kubeClient.CoreV1().ConfigMaps("jx-staging").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, configMaps.Items, 1)
+	configMap := configMaps.Items[0]
+	assert.Equal(t, "configmap://jx-staging/"+configMap.Name, location)
+	assert.Equal(t, data, configMap.BinaryData[ReportArchiveKey])
+	assert.Equal(t, checksum, configMap.Annotations[AnnotationArchiveChecksum])
+	assert.Empty(t, configMap.Labels)
+	assert.Equal(t, []metav1.OwnerReference{owner}, configMap.OwnerReferences)
+}
+
+func TestConfigMapStoreRejectsLargeArchive(t *testing.T) {
+	store := &ConfigMapStore{KubeClient: kubeFake.NewSimpleClientset(), MaxBytes: 4}
+	data := []byte("report")
+
+	_, err := store.Store(context.TODO(), "jx-staging", ArchiveChecksum(data), data)
+
+	assert.Error(t, err)
+}
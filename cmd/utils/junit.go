@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"encoding/xml"
+	"fmt"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Time   float64      `xml:"time,attr"`
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitCase struct {
+	Name      string    `xml:"name,attr"`
+	ClassName string    `xml:"classname,attr"`
+	Time      float64   `xml:"time,attr"`
+	Failures  []xmlNode `xml:"failure"`
+	Errors    []xmlNode `xml:"error"`
+	Skipped   *xmlNode  `xml:"skipped"`
+}
+
+type xmlNode struct {
+	Message string `xml:"message,attr"`
+}
+
+// ParseJUnitReports summarizes test cases of every JUnit XML file matched by patterns
+func ParseJUnitReports(patterns []string) (*largetestv1beta1.TestSummary, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid junit glob %q: %w", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no junit reports found by %v", patterns)
+	}
+	sort.Strings(files)
+
+	summary := &largetestv1beta1.TestSummary{}
+	var failedTests []string
+	var duration float64
+	for _, file := range files {
+		suites, err := readJUnitFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, suite := range suites {
+			duration += summarizeSuite(suite, summary, &failedTests)
+		}
+	}
+
+	summary.Duration = metav1.Duration{Duration: time.Duration(duration * float64(time.Second)).Round(time.Millisecond)}
+	if len(failedTests) > largetestv1beta1.MaxFailedTests {
+		summary.FailedTestsOmitted = len(failedTests) - largetestv1beta1.MaxFailedTests
+		failedTests = failedTests[:largetestv1beta1.MaxFailedTests]
+	}
+	summary.FailedTests = failedTests
+	return summary, nil
+}
+
+// readJUnitFile reads either <testsuites> or a single <testsuite> root element
+func readJUnitFile(file string) ([]junitSuite, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	suites := junitSuites{}
+	if err = xml.Unmarshal(data, &suites); err == nil {
+		return suites.Suites, nil
+	}
+
+	suite := junitSuite{}
+	if err = xml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("can't parse junit report %s: %w", file, err)
+	}
+	return []junitSuite{suite}, nil
+}
+
+// summarizeSuite adds test cases of suite and its nested suites to summary and returns suite duration in seconds
+func summarizeSuite(suite junitSuite, summary *largetestv1beta1.TestSummary, failedTests *[]string) float64 {
+	var casesTime float64
+	for _, testCase := range suite.Cases {
+		summary.Total++
+		casesTime += testCase.Time
+
+		name := testCase.Name
+		if testCase.ClassName != "" {
+			name = testCase.ClassName + "." + testCase.Name
+		}
+		switch {
+		case len(testCase.Errors) > 0:
+			summary.Errors++
+			*failedTests = append(*failedTests, name)
+		case len(testCase.Failures) > 0:
+			summary.Failed++
+			*failedTests = append(*failedTests, name)
+		case testCase.Skipped != nil:
+			summary.Skipped++
+		default:
+			summary.Passed++
+		}
+	}
+
+	var nestedTime float64
+	for _, nested := range suite.Suites {
+		nestedTime += summarizeSuite(nested, summary, failedTests)
+	}
+
+	if suite.Time > 0 {
+		return suite.Time
+	}
+	return casesTime + nestedTime
+}
+
+// ResultOfTests derives execution result from summary: any failed or errored test fails the execution,
+// a report without test cases means the tests didn't run
+func ResultOfTests(summary *largetestv1beta1.TestSummary) largetestv1beta1.Result {
+	switch {
+	case summary.Total == 0:
+		return largetestv1beta1.ResultError
+	case summary.Failed > 0 || summary.Errors > 0:
+		return largetestv1beta1.ResultFailed
+	}
+	return largetestv1beta1.ResultSucceeded
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"testing"
+	"time"
+)
+
+func TestParseJUnitReports(t *testing.T) {
+	summary, err := ParseJUnitReports([]string{"testdata/junit/*.xml"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 5, summary.Total)
+	assert.Equal(t, 2, summary.Passed)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.Equal(t, 1, summary.Errors)
+	assert.Equal(t, 16*time.Second, summary.Duration.Duration)
+	assert.Equal(t, []string{"orders.CreateOrder.rejects empty order", "checkout"}, summary.FailedTests)
+	assert.Equal(t, largetestv1beta1.ResultFailed, ResultOfTests(summary))
+}
+
+func TestParseJUnitReportsPassed(t *testing.T) {
+	summary, err := ParseJUnitReports([]string{"testdata/smoke.xml"})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary.Passed)
+	assert.Empty(t, summary.FailedTests)
+	assert.Equal(t, largetestv1beta1.ResultSucceeded, ResultOfTests(summary))
+}
+
+func TestParseJUnitReportsWithoutFiles(t *testing.T) {
+	_, err := ParseJUnitReports([]string{"testdata/missing/*.xml"})
+
+	assert.Error(t, err)
+}
+
+func TestResultOfEmptyTests(t *testing.T) {
+	assert.Equal(t, largetestv1beta1.ResultError, ResultOfTests(&largetestv1beta1.TestSummary{}))
+}
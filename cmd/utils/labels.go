@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const maxLabelValueLength = 63
+
+var invalidLabelValueChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// LabelValue replaces characters which are not allowed in label values and truncates value to 63 characters
+func LabelValue(value string) string {
+	sanitized := invalidLabelValueChars.ReplaceAllString(value, "_")
+	if len(sanitized) > maxLabelValueLength {
+		sanitized = sanitized[:maxLabelValueLength]
+	}
+	return strings.Trim(sanitized, "._-")
+}
+
+// RepositoryLabelValue turns git url into host_owner_name label value, e.g. github.com_vitech-team_sdlcctl
+func RepositoryLabelValue(gitURL string) string {
+	repository := StripCredentials(gitURL)
+	if index := strings.Index(repository, "://"); index >= 0 {
+		repository = repository[index+3:]
+	}
+	if index := strings.Index(repository, "@"); index >= 0 {
+		repository = repository[index+1:]
+	}
+	repository = strings.TrimSuffix(strings.TrimSuffix(repository, "/"), ".git")
+	return LabelValue(strings.NewReplacer(":", "_", "/", "_").Replace(repository))
+}
+
+// StripCredentials removes user info, e.g. a token, from http(s) git url
+func StripCredentials(gitURL string) string {
+	parsed, err := url.Parse(gitURL)
+	if err != nil || parsed.User == nil || parsed.Scheme == "" {
+		return gitURL
+	}
+	parsed.User = nil
+	return parsed.String()
+}
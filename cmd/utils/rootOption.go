@@ -5,12 +5,25 @@ import (
 	"github.com/spf13/cobra"
 	sdlcClient "github.com/vitech-team/sdlcctl/client/clientset/versioned"
 	k8s "k8s.io/client-go/kubernetes"
+	"os"
+)
+
+const (
+	// DefaultEnvNamespace is the namespace of Jenkins X dev environment where Environment resources are stored
+	DefaultEnvNamespace = "jx"
+	// EnvNamespaceEnvVar overrides default of --env-namespace
+	EnvNamespaceEnvVar = "SDLC_ENV_NAMESPACE"
 )
 
 type Options struct {
-	Helmfile    string
-	HelmfileDir string
-	GitUrl      string
+	Helmfile            string
+	HelmfileDir         string
+	HelmfileEnvironment string
+	GitUrl              string
+	BaseRef             string
+	HeadRef             string
+	HeadDir             string
+	EnvNamespace        string
 
 	JxClient   jxClient.Interface
 	LtClient   sdlcClient.Interface
@@ -34,6 +47,14 @@ func (options *Options) AddBaseFlags(cmd *cobra.Command) {
 		"HelmFiles root directory",
 	)
 
+	cmd.PersistentFlags().StringVarP(
+		&options.HelmfileEnvironment,
+		"environment",
+		"",
+		"default",
+		"helmfile environment used to render templated helmfiles",
+	)
+
 	cmd.PersistentFlags().StringVarP(
 		&options.GitUrl,
 		"gitUrl",
@@ -42,11 +63,43 @@ func (options *Options) AddBaseFlags(cmd *cobra.Command) {
 		"Git url where helmfiles stored",
 	)
 
+	cmd.PersistentFlags().StringVarP(
+		&options.BaseRef,
+		"base-ref",
+		"",
+		"",
+		"git ref (branch, tag or SHA) of --gitUrl used as comparison baseline, default branch if empty",
+	)
+
+	cmd.PersistentFlags().StringVarP(
+		&options.HeadRef,
+		"head-ref",
+		"",
+		"",
+		"git ref (branch, tag or SHA) of --gitUrl used as compared helmfiles instead of --head-dir",
+	)
+
+	cmd.PersistentFlags().StringVarP(
+		&options.HeadDir,
+		"head-dir",
+		"",
+		"",
+		"directory with compared helmfiles, --hfd if empty",
+	)
+
+	cmd.PersistentFlags().StringVarP(
+		&options.EnvNamespace,
+		"env-namespace",
+		"",
+		EnvOrDefault(EnvNamespaceEnvVar, DefaultEnvNamespace),
+		"namespace with Jenkins X Environment resources, $"+EnvNamespaceEnvVar+" is used as default if set",
+	)
+
 	if err := cmd.MarkPersistentFlagDirname("hfd"); err != nil {
 		panic(err.Error())
 	}
 
-	if err := cmd.MarkPersistentFlagRequired("gitUrl"); err != nil {
+	if err := cmd.MarkPersistentFlagDirname("head-dir"); err != nil {
 		panic(err.Error())
 	}
 
@@ -54,3 +107,11 @@ func (options *Options) AddBaseFlags(cmd *cobra.Command) {
 		panic(err.Error())
 	}
 }
+
+// EnvOrDefault returns value of environment variable name or defaultValue when it is empty
+func EnvOrDefault(name string, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
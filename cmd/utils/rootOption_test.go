@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestEnvNamespaceDefaultsToEnvVar(t *testing.T) {
+	require.NoError(t, os.Setenv(EnvNamespaceEnvVar, "platform"))
+	defer os.Unsetenv(EnvNamespaceEnvVar)
+
+	options := Options{}
+	cmd := &cobra.Command{Use: "sdlc", Run: func(cmd *cobra.Command, args []string) {}}
+	options.AddBaseFlags(cmd)
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, "platform", options.EnvNamespace)
+}
+
+func TestEnvNamespaceFlagOverridesEnvVar(t *testing.T) {
+	require.NoError(t, os.Setenv(EnvNamespaceEnvVar, "platform"))
+	defer os.Unsetenv(EnvNamespaceEnvVar)
+
+	options := Options{}
+	cmd := &cobra.Command{Use: "sdlc", Run: func(cmd *cobra.Command, args []string) {}}
+	options.AddBaseFlags(cmd)
+	cmd.SetArgs([]string{"--env-namespace", "dev"})
+	require.NoError(t, cmd.Execute())
+
+	assert.Equal(t, "dev", options.EnvNamespace)
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+)
+
+// ClassifyBump returns which part of semantic version has been changed between previous and next version,
+// versions which can't be parsed are classified as unknown and versions with equal precedence as no bump
+func ClassifyBump(previous string, next string) largetestv1beta1.Bump {
+	prevVersion, err := semver.NewVersion(previous)
+	if err != nil {
+		return largetestv1beta1.BumpUnknown
+	}
+	nextVersion, err := semver.NewVersion(next)
+	if err != nil {
+		return largetestv1beta1.BumpUnknown
+	}
+
+	switch {
+	case nextVersion.LessThan(prevVersion):
+		return largetestv1beta1.BumpDowngrade
+	case nextVersion.Equal(prevVersion):
+		return ""
+	case nextVersion.Major() != prevVersion.Major():
+		return largetestv1beta1.BumpMajor
+	case nextVersion.Minor() != prevVersion.Minor():
+		return largetestv1beta1.BumpMinor
+	case nextVersion.Patch() != prevVersion.Patch():
+		return largetestv1beta1.BumpPatch
+	}
+	return largetestv1beta1.BumpPrerelease
+}
+
+// OnlyBumps checks if topology has been updated and every change is one of allowed bumps,
+// topologies with added or removed apps never match
+func OnlyBumps(topology []largetestv1beta1.AppVersion, allowed []largetestv1beta1.Bump) bool {
+	allowedBumps := map[largetestv1beta1.Bump]bool{}
+	for _, bump := range allowed {
+		allowedBumps[bump] = true
+	}
+
+	updated := false
+	for _, app := range topology {
+		switch app.State {
+		case largetestv1beta1.StateSame:
+		case largetestv1beta1.StateUpdated:
+			if !allowedBumps[app.Bump] {
+				return false
+			}
+			updated = true
+		default:
+			return false
+		}
+	}
+	return updated
+}
+
+// Relative version constraints which are expanded with the target version
+const (
+	// ConstraintSameMinor accepts versions of the target major.minor, e.g. ~1.2.3 is >=1.2.3 <1.3.0
+	ConstraintSameMinor = "~"
+	// ConstraintSameMajor accepts versions of the target major, e.g. ^1.2.3 is >=1.2.3 <2.0.0
+	ConstraintSameMajor = "^"
+)
+
+// ValidateConstraint checks that constraint is a semver range or a relative constraint
+func ValidateConstraint(constraint string) error {
+	switch constraint {
+	case "", ConstraintSameMinor, ConstraintSameMajor:
+		return nil
+	}
+	_, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+	return nil
+}
+
+// SatisfiesConstraint checks tested version against constraint, relative constraints are expanded with target version
+// and empty constraint requires the same version as target
+func SatisfiesConstraint(constraint string, target string, tested string) (bool, error) {
+	testedVersion, testedErr := semver.NewVersion(tested)
+	switch constraint {
+	case "":
+		targetVersion, err := semver.NewVersion(target)
+		if err != nil || testedErr != nil {
+			return target == tested, nil
+		}
+		return targetVersion.Equal(testedVersion), nil
+	case ConstraintSameMinor, ConstraintSameMajor:
+		constraint += target
+	}
+
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+	if testedErr != nil {
+		return false, nil
+	}
+	return constraints.Check(testedVersion), nil
+}
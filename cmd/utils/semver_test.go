@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"testing"
+)
+
+func TestClassifyBump(t *testing.T) {
+	for _, tc := range []struct {
+		previous string
+		next     string
+		expected largetestv1beta1.Bump
+	}{
+		{"1.2.3", "2.0.0", largetestv1beta1.BumpMajor},
+		{"v1.2.3", "v1.3.0", largetestv1beta1.BumpMinor},
+		{"1.2.3", "1.2.4", largetestv1beta1.BumpPatch},
+		{"1.2.3", "1.2.4-rc.1", largetestv1beta1.BumpPatch},
+		{"1.2.4-rc.1", "1.2.4-rc.2", largetestv1beta1.BumpPrerelease},
+		{"1.2.4-rc.2", "1.2.4", largetestv1beta1.BumpPrerelease},
+		{"1.2.4", "1.2.3", largetestv1beta1.BumpDowngrade},
+		{"1.2.3", "v1.2.3", ""},
+		{"latest", "1.2.3", largetestv1beta1.BumpUnknown},
+	} {
+		assert.Equal(t, tc.expected, ClassifyBump(tc.previous, tc.next), "%s -> %s", tc.previous, tc.next)
+	}
+}
+
+func TestOnlyBumps(t *testing.T) {
+	allowed := []largetestv1beta1.Bump{largetestv1beta1.BumpPatch}
+	same := largetestv1beta1.AppVersion{Name: "billing", State: largetestv1beta1.StateSame}
+	patch := largetestv1beta1.AppVersion{Name: "orders", State: largetestv1beta1.StateUpdated, Bump: largetestv1beta1.BumpPatch}
+	minor := largetestv1beta1.AppVersion{Name: "orders", State: largetestv1beta1.StateUpdated, Bump: largetestv1beta1.BumpMinor}
+	added := largetestv1beta1.AppVersion{Name: "search", State: largetestv1beta1.StateAdded}
+
+	assert.True(t, OnlyBumps([]largetestv1beta1.AppVersion{same, patch}, allowed))
+	assert.False(t, OnlyBumps([]largetestv1beta1.AppVersion{same, minor}, allowed))
+	assert.False(t, OnlyBumps([]largetestv1beta1.AppVersion{patch, added}, allowed))
+	assert.False(t, OnlyBumps([]largetestv1beta1.AppVersion{same}, allowed))
+}
+
+func TestSatisfiesConstraint(t *testing.T) {
+	for _, tc := range []struct {
+		constraint string
+		target     string
+		tested     string
+		expected   bool
+	}{
+		{"", "1.2.3", "v1.2.3", true},
+		{"", "1.2.3", "1.2.4", false},
+		{"", "latest", "latest", true},
+		{"~", "1.2.3", "1.2.9", true},
+		{"~", "1.2.3", "1.3.0", false},
+		{"~", "1.2.3", "1.2.2", false},
+		{"^", "1.2.3", "1.9.0", true},
+		{"^", "1.2.3", "2.0.0", false},
+		{">=1.0.0, <2.0.0", "1.2.3", "1.0.5", true},
+		{">=1.0.0, <2.0.0", "1.2.3", "latest", false},
+	} {
+		satisfied, err := SatisfiesConstraint(tc.constraint, tc.target, tc.tested)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, satisfied, "%s %s -> %s", tc.constraint, tc.target, tc.tested)
+	}
+}
+
+func TestValidateConstraint(t *testing.T) {
+	assert.NoError(t, ValidateConstraint("~"))
+	assert.NoError(t, ValidateConstraint(">= 1.2, < 2"))
+	assert.Error(t, ValidateConstraint("about 1.2"))
+
+	_, err := SatisfiesConstraint("about", "1.2.3", "1.2.3")
+	assert.Error(t, err)
+}
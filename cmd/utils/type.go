@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	jxV1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
 	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"sort"
+	"strings"
 )
 
 type Environment struct {
@@ -10,14 +14,57 @@ type Environment struct {
 	Topology         []largetestv1beta1.AppVersion `json:"topology"`
 	Changed          bool                          `json:"changed"`
 	Tested           bool                          `json:"tested"`
+	// Execution is <namespace>/<name> of large test execution which is evidence of testing or is rejected as such
+	Execution string                  `json:"execution,omitempty"`
+	Result    largetestv1beta1.Result `json:"result,omitempty"`
 	jxV1.Environment
 }
 
+// ContainsVersion checks if topology has an app with the same name and version, comparison state is ignored
 func ContainsVersion(version largetestv1beta1.AppVersion, topology []largetestv1beta1.AppVersion) bool {
 	for _, tpVersion := range topology {
-		if version == tpVersion {
+		if version.Name == tpVersion.Name && version.Version == tpVersion.Version {
 			return true
 		}
 	}
 	return false
 }
+
+// ActiveTopology returns apps which are deployed in compared topology, i.e. all except removed ones
+func ActiveTopology(topology []largetestv1beta1.AppVersion) []largetestv1beta1.AppVersion {
+	var active []largetestv1beta1.AppVersion
+	for _, app := range topology {
+		if app.State != largetestv1beta1.StateRemoved {
+			active = append(active, app)
+		}
+	}
+	return active
+}
+
+// CountStates returns number of apps per comparison state
+func CountStates(topology []largetestv1beta1.AppVersion) map[largetestv1beta1.State]int {
+	counts := map[largetestv1beta1.State]int{}
+	for _, app := range topology {
+		counts[app.State]++
+	}
+	return counts
+}
+
+// TopologyKey is a canonical form of active topology, sorted name:version pairs, equal for the same deployed apps
+func TopologyKey(topology []largetestv1beta1.AppVersion) string {
+	var apps []string
+	for _, app := range ActiveTopology(topology) {
+		apps = append(apps, app.Name+":"+app.Version)
+	}
+	sort.Strings(apps)
+	return strings.Join(apps, ",")
+}
+
+// topologyHashLength keeps hash short enough to be a label value
+const topologyHashLength = 32
+
+// TopologyHash is a label friendly fingerprint of TopologyKey
+func TopologyHash(topology []largetestv1beta1.AppVersion) string {
+	sum := sha256.Sum256([]byte(TopologyKey(topology)))
+	return hex.EncodeToString(sum[:])[:topologyHashLength]
+}
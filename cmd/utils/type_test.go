@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"testing"
+)
+
+func TestTopologyKey(t *testing.T) {
+	key := TopologyKey([]largetestv1beta1.AppVersion{
+		{Name: "orders", Version: "1.1.0", State: largetestv1beta1.StateUpdated},
+		{Name: "legacy", Version: "0.9.0", State: largetestv1beta1.StateRemoved},
+		{Name: "billing", Version: "2.0.0"},
+	})
+
+	assert.Equal(t, "billing:2.0.0,orders:1.1.0", key)
+	assert.Equal(t, key, TopologyKey([]largetestv1beta1.AppVersion{
+		{Name: "billing", Version: "2.0.0"},
+		{Name: "orders", Version: "1.1.0"},
+	}))
+}
+
+func TestTopologyHash(t *testing.T) {
+	hash := TopologyHash([]largetestv1beta1.AppVersion{
+		{Name: "orders", Version: "1.1.0", State: largetestv1beta1.StateUpdated},
+		{Name: "legacy", Version: "0.9.0", State: largetestv1beta1.StateRemoved},
+		{Name: "billing", Version: "2.0.0"},
+	})
+
+	assert.Len(t, hash, 32)
+	assert.Regexp(t, "^[0-9a-f]+$", hash)
+	assert.Equal(t, hash, TopologyHash([]largetestv1beta1.AppVersion{
+		{Name: "billing", Version: "2.0.0"},
+		{Name: "orders", Version: "1.1.0"},
+	}))
+	assert.NotEqual(t, hash, TopologyHash([]largetestv1beta1.AppVersion{
+		{Name: "billing", Version: "2.0.1"},
+		{Name: "orders", Version: "1.1.0"},
+	}))
+}
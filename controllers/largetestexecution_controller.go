@@ -0,0 +1,329 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	largetestv1beta1 "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/cmd/utils"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// TestContainerName is the container of the Job which runs Spec.Image
+const TestContainerName = "large-test"
+
+// JobTTLAfterFinished lets Kubernetes delete finished Job with its pods, leaving a day to record the result
+// and to read the logs
+const JobTTLAfterFinished = int32(24 * 60 * 60)
+
+// maxJobNameLength keeps job-name label of Job pods valid
+const maxJobNameLength = 63
+
+// LargeTestExecutionReconciler runs Spec.Image of pending executions as Jobs in Spec.Namespace
+// and reflects Job progress in execution status
+type LargeTestExecutionReconciler struct {
+	client.Client
+	// APIReader reads Jobs bypassing the cache, which may not have seen just created Job yet, and pods which
+	// are not cached, Client by default
+	APIReader client.Reader
+	// Namespace is the only namespace Jobs can be run in when the cache is restricted to it, any namespace if empty
+	Namespace string
+	Log       logr.Logger
+	// Now is the clock of status timestamps, metav1.Now by default
+	Now func() metav1.Time
+}
+
+// +kubebuilder:rbac:groups=largetest.vitechteam.com,resources=largetestexecutions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=largetest.vitechteam.com,resources=largetestexecutions/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list
+
+func (r *LargeTestExecutionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("largetestexecution", req.NamespacedName)
+
+	lte := &largetestv1beta1.LargeTestExecution{}
+	if err := r.Get(ctx, req.NamespacedName, lte); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.deleteJobs(ctx, log, req.NamespacedName)
+		}
+		return ctrl.Result{}, err
+	}
+	if lte.Spec.Image == "" {
+		return ctrl.Result{}, nil
+	}
+
+	switch lte.Status.Result {
+	case largetestv1beta1.ResultPending:
+		if r.Namespace != "" && lte.Spec.Namespace != r.Namespace {
+			return ctrl.Result{}, r.rejectNamespace(ctx, log, lte)
+		}
+		return ctrl.Result{}, r.startJob(ctx, log, lte)
+	case largetestv1beta1.ResultRunning:
+		if lte.Status.JobName == "" {
+			// running executions which are not run by the controller are reported by CI
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.syncJob(ctx, log, lte)
+	}
+	return ctrl.Result{}, nil
+}
+
+// deleteJobs deletes Jobs of deleted execution with their pods, Job in another namespace can't be owned by
+// the execution and is not garbage collected
+func (r *LargeTestExecutionReconciler) deleteJobs(ctx context.Context, log logr.Logger, execution types.NamespacedName) error {
+	jobs := &batchv1.JobList{}
+	err := r.List(ctx, jobs, client.MatchingLabels{
+		largetestv1beta1.LabelExecution:          execution.Name,
+		largetestv1beta1.LabelExecutionNamespace: execution.Namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("can't list jobs of deleted execution %s: %w", execution, err)
+	}
+
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		err = r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("can't delete job %s/%s: %w", job.Namespace, job.Name, err)
+		}
+		log.Info("large test job of deleted execution has been deleted", "job", job.Namespace+"/"+job.Name)
+	}
+	return nil
+}
+
+func (r *LargeTestExecutionReconciler) startJob(ctx context.Context, log logr.Logger, lte *largetestv1beta1.LargeTestExecution) error {
+	job, err := NewJob(lte)
+	if err != nil {
+		return err
+	}
+	err = r.Create(ctx, job)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("can't create job %s/%s: %w", job.Namespace, job.Name, err)
+	}
+	log.Info("large test job has been created", "job", job.Namespace+"/"+job.Name)
+
+	lte.Status.JobName = job.Name
+	lte.SetResult(largetestv1beta1.ResultRunning, r.now())
+	return r.Status().Update(ctx, lte)
+}
+
+// rejectNamespace finishes execution whose Job would be run in namespace which is not watched, its progress
+// would never be seen
+func (r *LargeTestExecutionReconciler) rejectNamespace(ctx context.Context, log logr.Logger, lte *largetestv1beta1.LargeTestExecution) error {
+	log.Info("large test execution namespace is not watched", "namespace", lte.Spec.Namespace, "watched", r.Namespace)
+	lte.SetResult(largetestv1beta1.ResultError, r.now())
+	meta.SetStatusCondition(&lte.Status.Conditions, metav1.Condition{
+		Type:               largetestv1beta1.ConditionCompleted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "NamespaceNotWatched",
+		Message:            fmt.Sprintf("controller runs large tests only in namespace %s, not in %s", r.Namespace, lte.Spec.Namespace),
+		ObservedGeneration: lte.Generation,
+	})
+	return r.Status().Update(ctx, lte)
+}
+
+func (r *LargeTestExecutionReconciler) syncJob(ctx context.Context, log logr.Logger, lte *largetestv1beta1.LargeTestExecution) error {
+	original := lte.Status.DeepCopy()
+
+	job := &batchv1.Job{}
+	key := types.NamespacedName{Namespace: lte.Spec.Namespace, Name: lte.Status.JobName}
+	err := r.Get(ctx, key, job)
+	if apierrors.IsNotFound(err) {
+		// cache may lag behind the Job created by startJob
+		err = r.apiReader().Get(ctx, key, job)
+	}
+	if apierrors.IsNotFound(err) {
+		log.Info("large test job has been deleted before completion", "job", lte.Status.JobName)
+		lte.SetResult(largetestv1beta1.ResultError, r.now())
+		return r.Status().Update(ctx, lte)
+	}
+	if err != nil {
+		return fmt.Errorf("can't get job %s/%s: %w", lte.Spec.Namespace, lte.Status.JobName, err)
+	}
+
+	if job.Status.StartTime != nil {
+		lte.Status.StartTime = job.Status.StartTime.DeepCopy()
+	}
+	logs, err := r.podOfJob(ctx, job)
+	if err != nil {
+		return err
+	}
+	if logs != "" {
+		lte.Status.Logs = logs
+	}
+	if result, finished := JobResult(job); result != "" {
+		log.Info("large test job has finished", "job", job.Name, "result", result)
+		lte.SetResult(result, finished)
+	}
+
+	if equality.Semantic.DeepEqual(original, &lte.Status) {
+		return nil
+	}
+	return r.Status().Update(ctx, lte)
+}
+
+// podOfJob references the latest pod of the Job as <namespace>/<pod>, pods are read from API server
+// so that manager does not cache pods of the whole cluster
+func (r *LargeTestExecutionReconciler) podOfJob(ctx context.Context, job *batchv1.Job) (string, error) {
+	pods := &corev1.PodList{}
+	err := r.apiReader().List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name})
+	if err != nil {
+		return "", fmt.Errorf("can't list pods of job %s/%s: %w", job.Namespace, job.Name, err)
+	}
+
+	var latest *corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if latest == nil || latest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			latest = pod
+		}
+	}
+	if latest == nil {
+		return "", nil
+	}
+	return latest.Namespace + "/" + latest.Name, nil
+}
+
+func (r *LargeTestExecutionReconciler) apiReader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+	return r.APIReader
+}
+
+func (r *LargeTestExecutionReconciler) now() metav1.Time {
+	if r.Now == nil {
+		return metav1.Now()
+	}
+	return r.Now()
+}
+
+// JobResult maps finished Job to execution result and its completion time, result is empty while Job is active
+func JobResult(job *batchv1.Job) (largetestv1beta1.Result, metav1.Time) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return largetestv1beta1.ResultSucceeded, condition.LastTransitionTime
+		case batchv1.JobFailed:
+			// tests didn't finish in time, so they neither passed nor failed
+			if condition.Reason == "DeadlineExceeded" {
+				return largetestv1beta1.ResultError, condition.LastTransitionTime
+			}
+			return largetestv1beta1.ResultFailed, condition.LastTransitionTime
+		}
+	}
+	return "", metav1.Time{}
+}
+
+// NewJob makes Job which runs Spec.Image once in Spec.Namespace, tested topology is passed as env variables
+func NewJob(lte *largetestv1beta1.LargeTestExecution) (*batchv1.Job, error) {
+	if lte.Spec.Namespace == "" {
+		return nil, fmt.Errorf("large test execution %s/%s has no namespace to run tests in", lte.Namespace, lte.Name)
+	}
+	topology, err := json.Marshal(utils.ActiveTopology(lte.Spec.Topology))
+	if err != nil {
+		return nil, fmt.Errorf("can't marshal topology of %s/%s: %w", lte.Namespace, lte.Name, err)
+	}
+
+	labels := map[string]string{
+		largetestv1beta1.LabelExecution:          lte.Name,
+		largetestv1beta1.LabelExecutionNamespace: lte.Namespace,
+	}
+	backoffLimit := int32(0)
+	ttl := JobTTLAfterFinished
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      JobName(lte),
+			Namespace: lte.Spec.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:  TestContainerName,
+							Image: lte.Spec.Image,
+							Env: []corev1.EnvVar{
+								{Name: "SDLC_EXECUTION", Value: lte.Namespace + "/" + lte.Name},
+								{Name: "SDLC_ENVIRONMENT", Value: lte.Spec.Environment},
+								{Name: "SDLC_NAMESPACE", Value: lte.Spec.Namespace},
+								{Name: "SDLC_REPORT", Value: lte.Spec.Report},
+								{Name: "SDLC_COMMIT", Value: lte.Spec.Commit},
+								{Name: "SDLC_SUITE", Value: lte.Spec.Suite},
+								{Name: "SDLC_TOPOLOGY", Value: utils.TopologyKey(lte.Spec.Topology)},
+								{Name: "SDLC_TOPOLOGY_JSON", Value: string(topology)},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// JobName is unique for execution namespace and name and short enough to be used as a label value
+func JobName(lte *largetestv1beta1.LargeTestExecution) string {
+	sum := sha256.Sum256([]byte(lte.Namespace + "/" + lte.Name))
+	suffix := "-" + hex.EncodeToString(sum[:])[:8]
+	name := lte.Name
+	if len(name)+len(suffix) > maxJobNameLength {
+		name = name[:maxJobNameLength-len(suffix)]
+	}
+	return name + suffix
+}
+
+// executionOfJob enqueues execution referenced by Job labels
+func executionOfJob(object client.Object) []reconcile.Request {
+	labels := object.GetLabels()
+	name, namespace := labels[largetestv1beta1.LabelExecution], labels[largetestv1beta1.LabelExecutionNamespace]
+	if name == "" || namespace == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+}
+
+func (r *LargeTestExecutionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&largetestv1beta1.LargeTestExecution{}).
+		Watches(&source.Kind{Type: &batchv1.Job{}}, handler.EnqueueRequestsFromMapFunc(executionOfJob)).
+		Complete(r)
+}
@@ -0,0 +1,282 @@
+package controllers_test
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/controllers"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+var reconcileNow = metav1.NewTime(time.Date(2021, 4, 20, 12, 0, 0, 0, time.UTC))
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, sdlc.AddToScheme(scheme))
+	return scheme
+}
+
+func pendingExecution() *sdlc.LargeTestExecution {
+	lte := &sdlc.LargeTestExecution{
+		ObjectMeta: metav1.ObjectMeta{Name: "jx-staging-abc", Namespace: "jx-staging"},
+		Spec: sdlc.LargeTestExecutionSpec{
+			Image:       "gcr.io/tests:1.0.0",
+			Environment: "staging",
+			Namespace:   "jx-staging",
+			Report:      "https://reports.example.com/1",
+			Commit:      "abc",
+			Topology: []sdlc.AppVersion{
+				{Name: "orders-svc", Version: "2.3.1", State: sdlc.StateUpdated},
+				{Name: "legacy-svc", Version: "0.1.0", State: sdlc.StateRemoved},
+				{Name: "payments-svc", Version: "1.0.4", State: sdlc.StateSame},
+			},
+		},
+	}
+	lte.SetResult(sdlc.ResultPending, reconcileNow)
+	return lte
+}
+
+func newReconciler(c client.Client) *controllers.LargeTestExecutionReconciler {
+	return &controllers.LargeTestExecutionReconciler{
+		Client: c,
+		Log:    logr.Discard(),
+		Now:    func() metav1.Time { return reconcileNow },
+	}
+}
+
+func reconcileExecution(t *testing.T, c client.Client, lte *sdlc.LargeTestExecution) *sdlc.LargeTestExecution {
+	return reconcileWith(t, newReconciler(c), lte)
+}
+
+func reconcileWith(t *testing.T, reconciler *controllers.LargeTestExecutionReconciler, lte *sdlc.LargeTestExecution) *sdlc.LargeTestExecution {
+	c := reconciler.Client
+	key := types.NamespacedName{Namespace: lte.Namespace, Name: lte.Name}
+	_, err := reconciler.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+
+	reconciled := &sdlc.LargeTestExecution{}
+	require.NoError(t, c.Get(context.TODO(), key, reconciled))
+	return reconciled
+}
+
+func TestReconcilePendingExecutionCreatesJob(t *testing.T) {
+	lte := pendingExecution()
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(lte).Build()
+
+	reconciled := reconcileExecution(t, c, lte)
+
+	assert.Equal(t, sdlc.ResultRunning, reconciled.Status.Result)
+	assert.Equal(t, controllers.JobName(lte), reconciled.Status.JobName)
+	assert.Equal(t, reconcileNow.Unix(), reconciled.Status.StartTime.Unix())
+	assert.Nil(t, reconciled.Status.CompletionTime)
+
+	job := &batchv1.Job{}
+	require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "jx-staging", Name: reconciled.Status.JobName}, job))
+	assert.Equal(t, "jx-staging-abc", job.Labels[sdlc.LabelExecution])
+	assert.Equal(t, "jx-staging", job.Labels[sdlc.LabelExecutionNamespace])
+	assert.Equal(t, int32(0), *job.Spec.BackoffLimit)
+	assert.Equal(t, controllers.JobTTLAfterFinished, *job.Spec.TTLSecondsAfterFinished)
+	assert.Equal(t, corev1.RestartPolicyNever, job.Spec.Template.Spec.RestartPolicy)
+	require.Len(t, job.Spec.Template.Spec.Containers, 1)
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "gcr.io/tests:1.0.0", container.Image)
+	assert.Contains(t, container.Env, corev1.EnvVar{Name: "SDLC_TOPOLOGY", Value: "orders-svc:2.3.1,payments-svc:1.0.4"})
+	assert.Contains(t, container.Env, corev1.EnvVar{Name: "SDLC_ENVIRONMENT", Value: "staging"})
+	assert.Contains(t, container.Env, corev1.EnvVar{
+		Name: "SDLC_TOPOLOGY_JSON",
+		Value: `[{"name":"orders-svc","version":"2.3.1","state":"updated"},` +
+			`{"name":"payments-svc","version":"1.0.4","state":"same"}]`,
+	})
+}
+
+func TestReconcileCompletedJobSetsResult(t *testing.T) {
+	started := metav1.NewTime(reconcileNow.Add(time.Minute))
+	finished := metav1.NewTime(reconcileNow.Add(5 * time.Minute))
+
+	tests := map[string]struct {
+		condition batchv1.JobCondition
+		expected  sdlc.Result
+	}{
+		"complete": {
+			condition: batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: finished},
+			expected:  sdlc.ResultSucceeded,
+		},
+		"failed": {
+			condition: batchv1.JobCondition{
+				Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", LastTransitionTime: finished,
+			},
+			expected: sdlc.ResultFailed,
+		},
+		"deadline exceeded": {
+			condition: batchv1.JobCondition{
+				Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "DeadlineExceeded", LastTransitionTime: finished,
+			},
+			expected: sdlc.ResultError,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			lte := pendingExecution()
+			lte.Status.JobName = controllers.JobName(lte)
+			lte.SetResult(sdlc.ResultRunning, reconcileNow)
+			job, err := controllers.NewJob(lte)
+			require.NoError(t, err)
+			job.Status.StartTime = &started
+			job.Status.Conditions = []batchv1.JobCondition{test.condition}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Name: job.Name + "-x7k2p", Namespace: "jx-staging", Labels: map[string]string{"job-name": job.Name},
+			}}
+			c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(lte, job, pod).Build()
+
+			reconciled := reconcileExecution(t, c, lte)
+
+			assert.Equal(t, test.expected, reconciled.Status.Result)
+			assert.Equal(t, started.Unix(), reconciled.Status.StartTime.Unix())
+			assert.Equal(t, finished.Unix(), reconciled.Status.CompletionTime.Unix())
+			assert.Equal(t, "jx-staging/"+pod.Name, reconciled.Status.Logs)
+			assert.True(t, reconciled.IsCompleted())
+		})
+	}
+}
+
+func TestReconcileActiveJobKeepsRunning(t *testing.T) {
+	lte := pendingExecution()
+	lte.Status.JobName = controllers.JobName(lte)
+	lte.SetResult(sdlc.ResultRunning, reconcileNow)
+	job, err := controllers.NewJob(lte)
+	require.NoError(t, err)
+	job.Status.Active = 1
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(lte, job).Build()
+
+	reconciled := reconcileExecution(t, c, lte)
+
+	assert.Equal(t, sdlc.ResultRunning, reconciled.Status.Result)
+	assert.Nil(t, reconciled.Status.CompletionTime)
+}
+
+func TestReconcileReadsPodsFromAPIServer(t *testing.T) {
+	lte := pendingExecution()
+	lte.Status.JobName = controllers.JobName(lte)
+	lte.SetResult(sdlc.ResultRunning, reconcileNow)
+	job, err := controllers.NewJob(lte)
+	require.NoError(t, err)
+	job.Status.Active = 1
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: job.Name + "-x7k2p", Namespace: "jx-staging", Labels: map[string]string{"job-name": job.Name},
+	}}
+	reconciler := newReconciler(fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(lte, job).Build())
+	reconciler.APIReader = fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(job, pod).Build()
+
+	reconciled := reconcileWith(t, reconciler, lte)
+
+	assert.Equal(t, "jx-staging/"+pod.Name, reconciled.Status.Logs)
+}
+
+func TestReconcileDeletedJobIsError(t *testing.T) {
+	lte := pendingExecution()
+	lte.Status.JobName = controllers.JobName(lte)
+	lte.SetResult(sdlc.ResultRunning, reconcileNow)
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(lte).Build()
+
+	reconciled := reconcileExecution(t, c, lte)
+
+	assert.Equal(t, sdlc.ResultError, reconciled.Status.Result)
+}
+
+func TestReconcileJobMissingInCacheKeepsRunning(t *testing.T) {
+	lte := pendingExecution()
+	lte.Status.JobName = controllers.JobName(lte)
+	lte.SetResult(sdlc.ResultRunning, reconcileNow)
+	job, err := controllers.NewJob(lte)
+	require.NoError(t, err)
+	job.Status.Active = 1
+	reconciler := newReconciler(fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(lte).Build())
+	reconciler.APIReader = fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(job).Build()
+
+	reconciled := reconcileWith(t, reconciler, lte)
+
+	assert.Equal(t, sdlc.ResultRunning, reconciled.Status.Result)
+	assert.Nil(t, reconciled.Status.CompletionTime)
+}
+
+func TestReconcileDeletedExecutionDeletesJob(t *testing.T) {
+	lte := pendingExecution()
+	job, err := controllers.NewJob(lte)
+	require.NoError(t, err)
+	other := pendingExecution()
+	other.Name = "jx-staging-def"
+	otherJob, err := controllers.NewJob(other)
+	require.NoError(t, err)
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(other, job, otherJob).Build()
+
+	key := types.NamespacedName{Namespace: lte.Namespace, Name: lte.Name}
+	_, err = newReconciler(c).Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+
+	require.NoError(t, err)
+	jobs := &batchv1.JobList{}
+	require.NoError(t, c.List(context.TODO(), jobs))
+	require.Len(t, jobs.Items, 1)
+	assert.Equal(t, otherJob.Name, jobs.Items[0].Name)
+}
+
+func TestReconcileRejectsNamespaceNotWatched(t *testing.T) {
+	lte := pendingExecution()
+	lte.Spec.Namespace = "jx-production"
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(lte).Build()
+	reconciler := newReconciler(c)
+	reconciler.Namespace = "jx-staging"
+
+	reconciled := reconcileWith(t, reconciler, lte)
+
+	assert.Equal(t, sdlc.ResultError, reconciled.Status.Result)
+	require.Len(t, reconciled.Status.Conditions, 1)
+	assert.Equal(t, "NamespaceNotWatched", reconciled.Status.Conditions[0].Reason)
+	jobs := &batchv1.JobList{}
+	require.NoError(t, c.List(context.TODO(), jobs))
+	assert.Empty(t, jobs.Items)
+}
+
+func TestReconcileIgnoresExecutionsReportedByCI(t *testing.T) {
+	withoutImage := pendingExecution()
+	withoutImage.Name = "without-image"
+	withoutImage.Spec.Image = ""
+	reported := pendingExecution()
+	reported.Name = "reported"
+	reported.SetResult(sdlc.ResultRunning, reconcileNow)
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(withoutImage, reported).Build()
+
+	assert.Equal(t, sdlc.ResultPending, reconcileExecution(t, c, withoutImage).Status.Result)
+	assert.Equal(t, sdlc.ResultRunning, reconcileExecution(t, c, reported).Status.Result)
+
+	jobs := &batchv1.JobList{}
+	require.NoError(t, c.List(context.TODO(), jobs))
+	assert.Empty(t, jobs.Items)
+}
+
+func TestJobNameIsValidLabelValue(t *testing.T) {
+	lte := pendingExecution()
+	lte.Name = "jx-staging-" + strings.Repeat("0123456789", 6)
+
+	name := controllers.JobName(lte)
+
+	assert.Len(t, name, 63)
+	assert.True(t, strings.HasPrefix(name, "jx-staging-0123"))
+	other := lte.DeepCopy()
+	other.Namespace = "jx-production"
+	assert.NotEqual(t, name, controllers.JobName(other))
+}
@@ -0,0 +1,81 @@
+//go:build envtest
+// +build envtest
+
+package controllers_test
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdlc "github.com/vitech-team/sdlcctl/apis/largetest/v1beta1"
+	"github.com/vitech-team/sdlcctl/controllers"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"testing"
+	"time"
+)
+
+// TestControllerWithEnvtest runs the controller against kube-apiserver and etcd binaries of envtest,
+// run it with `go test -tags envtest ./controllers/` and KUBEBUILDER_ASSETS pointing to the binaries
+func TestControllerWithEnvtest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, envtest binaries are not available")
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{"../config/crd/bases"},
+		ErrorIfCRDPathMissing: true,
+	}
+	config, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() { _ = testEnv.Stop() }()
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{Scheme: newScheme(t), MetricsBindAddress: "0"})
+	require.NoError(t, err)
+	reconciler := &controllers.LargeTestExecutionReconciler{
+		Client: mgr.GetClient(), APIReader: mgr.GetAPIReader(), Log: logr.Discard(),
+	}
+	require.NoError(t, reconciler.SetupWithManager(mgr))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = mgr.Start(ctx)
+	}()
+
+	c := mgr.GetClient()
+	require.NoError(t, c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "jx-staging"}}))
+
+	lte := pendingExecution()
+	lte.Status = sdlc.LargeTestExecutionStatus{}
+	require.NoError(t, c.Create(ctx, lte))
+	lte.SetResult(sdlc.ResultPending, metav1.Now())
+	require.NoError(t, c.Status().Update(ctx, lte))
+
+	key := types.NamespacedName{Namespace: lte.Namespace, Name: lte.Name}
+	job := &batchv1.Job{}
+	require.Eventually(t, func() bool {
+		return c.Get(ctx, types.NamespacedName{Namespace: "jx-staging", Name: controllers.JobName(lte)}, job) == nil
+	}, 10*time.Second, 100*time.Millisecond, "job has not been created")
+	assert.Equal(t, "gcr.io/tests:1.0.0", job.Spec.Template.Spec.Containers[0].Image)
+
+	// there is no job controller in envtest, so the test completes the job instead
+	job.Status.Succeeded = 1
+	job.Status.Conditions = []batchv1.JobCondition{
+		{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now()},
+	}
+	require.NoError(t, c.Status().Update(ctx, job))
+
+	reconciled := &sdlc.LargeTestExecution{}
+	require.Eventually(t, func() bool {
+		return c.Get(ctx, key, reconciled) == nil && reconciled.Status.Result == sdlc.ResultSucceeded
+	}, 10*time.Second, 100*time.Millisecond, "execution has not succeeded")
+	assert.Equal(t, job.Name, reconciled.Status.JobName)
+	assert.NotNil(t, reconciled.Status.CompletionTime)
+}
@@ -3,6 +3,13 @@ module github.com/vitech-team/sdlcctl
 go 1.15
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.1
+	github.com/aws/aws-sdk-go v1.35.18
+	github.com/go-logr/logr v0.4.0
+	github.com/go-openapi/jsonpointer v0.19.5 // indirect
+	github.com/go-openapi/jsonreference v0.19.5 // indirect
+	github.com/go-openapi/swag v0.19.14 // indirect
+	github.com/imdario/mergo v0.3.12
 	github.com/jenkins-x-plugins/jx-changelog v0.0.42
 	github.com/jenkins-x-plugins/jx-release-version/v2 v2.4.2
 	github.com/jenkins-x/go-scm v1.6.18
@@ -11,15 +18,19 @@ require (
 	github.com/jenkins-x/jx-helpers v1.0.88
 	github.com/jenkins-x/jx-helpers/v3 v3.0.104
 	github.com/olekukonko/tablewriter v0.0.2
-	github.com/Masterminds/semver/v3 v3.1.1
 	github.com/roboll/helmfile v0.138.4
 	github.com/sirupsen/logrus v1.7.0
 	github.com/spf13/cobra v1.1.1
 	github.com/stretchr/testify v1.6.1
+	github.com/variantdev/vals v0.13.0
+	go.uber.org/zap v1.16.0
 	k8s.io/api v0.20.6
+	k8s.io/apiextensions-apiserver v0.20.1
 	k8s.io/apimachinery v0.20.6
 	k8s.io/client-go v11.0.1-0.20190805182717-6502b5e7b1b5+incompatible
+	k8s.io/kube-openapi v0.0.0-20201113171705-d219536bb9fd
 	sigs.k8s.io/controller-runtime v0.8.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (